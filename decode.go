@@ -1,38 +1,148 @@
 package csvutil
 
 import (
+	"bytes"
 	"encoding"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"reflect"
-	"regexp"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // Decoder manages reading data from a CSV into tagged structs.
 type Decoder struct {
 	r          *csv.Reader
+	structType reflect.Type
 	mappings   []csvField
 	numColumns int
+	// decoders holds the compiled conversion for each mapped column, parallel to mappings
+	decoders      []fieldDecoder
+	extra         *csvField
+	collectErrors bool
+	strict        bool
+	normalize     HeaderNormalizer
+	// headers holds the header row as found in the CSV, alongside the headers and
+	// struct fields that could not be matched to each other
+	headers        []string
+	extraHeaders   []string
+	unmappedFields []string
+	// missingDefaults holds the fields with a default value whose column is missing
+	missingDefaults []missingDefault
+}
+
+// missingDefault is an optional field with a default value but no column in the CSV,
+// which Read sets to its default for every row.
+type missingDefault struct {
+	field  csvField
+	decode fieldDecoder
+}
+
+// DecoderOption configures optional behavior of a Decoder.
+type DecoderOption func(*Decoder)
+
+// CollectErrors makes Read decode every mapped column of a row, even after one of them
+// fails, and return a *RowError listing every failing cell. Columns that decode
+// successfully are still populated.
+func CollectErrors() DecoderOption {
+	return func(d *Decoder) {
+		d.collectErrors = true
+	}
+}
+
+// DisallowUnknownHeaders makes the Decoder fail on creation when the CSV has a header
+// that does not match any struct field, instead of ignoring that column. It has no effect
+// on structs with an extra field, which collect every such column.
+func DisallowUnknownHeaders() DecoderOption {
+	return func(d *Decoder) {
+		d.strict = true
+	}
+}
+
+// Unmarshal decodes every row of the CSV data into the slice pointed to by v, which must
+// be a *[]T or *[]*T for a tagged struct type T. The slice is replaced by the decoded rows.
+func Unmarshal(data []byte, v interface{}, opts ...DecoderOption) error {
+	rv := reflect.ValueOf(v)
+	if v == nil || rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("Unmarshal destination must be a non-nil pointer to a slice")
+	}
+	sliceType := rv.Elem().Type()
+	structType := sliceType.Elem()
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("Unmarshal destination must be a slice of structs, found %s", sliceType)
+	}
+
+	d, err := NewDecoder(bytes.NewReader(data), structType, opts...)
+	if err != nil {
+		return err
+	}
+	rows := reflect.MakeSlice(sliceType, 0, 0)
+	for {
+		row := reflect.New(structType)
+		if err := d.read(row.Elem()); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if sliceType.Elem().Kind() == reflect.Ptr {
+			rows = reflect.Append(rows, row)
+		} else {
+			rows = reflect.Append(rows, row.Elem())
+		}
+	}
+	rv.Elem().Set(rows)
+	return nil
+}
+
+// UseHeaders is for CSVs without a header row: the Decoder maps columns to struct fields
+// using the given headers, in column order, and reads every row of the CSV as data.
+func UseHeaders(headers ...string) DecoderOption {
+	return func(d *Decoder) {
+		d.headers = append([]string{}, headers...)
+	}
 }
 
 // NewDecoder initializes itself with the headers of the CSV file to build mappings
-// to read data into structs.
-func NewDecoder(r io.Reader, dest interface{}) (Decoder, error) {
+// to read data into structs. dest describes the struct type to read into, and may be a
+// struct, a pointer to a struct, a slice of structs or a reflect.Type.
+func NewDecoder(r io.Reader, dest interface{}, opts ...DecoderOption) (Decoder, error) {
+	return NewDecoderFromCSVReader(newCSVReader(r), dest, opts...)
+}
+
+// newCSVReader creates the csv.Reader used by decoders that are not given one.
+func newCSVReader(r io.Reader) *csv.Reader {
 	csvR := csv.NewReader(r)
-	return NewDecoderFromCSVReader(csvR, dest)
+	// rows are converted into the destination struct before the next Read, so the
+	// csv.Reader can reuse its record slice
+	csvR.ReuseRecord = true
+	return csvR
 }
 
 // NewDecoderFromCSVReader intializes a decoder using the given csv.Reader.
 // This allows the caller to configure options on the csv.Reader (e.g. what
 // delimiter to use) instead of using the defaults.
-func NewDecoderFromCSVReader(csvR *csv.Reader, dest interface{}) (Decoder, error) {
-	mappings, err := structureFromStruct(dest)
+func NewDecoderFromCSVReader(csvR *csv.Reader, dest interface{}, opts ...DecoderOption) (Decoder, error) {
+	d := Decoder{r: csvR, normalize: LegacyHeaders}
+	for _, opt := range opts {
+		opt(&d)
+	}
+
+	structType, err := structTypeOf(dest)
+	if err != nil {
+		return Decoder{}, err
+	}
+	mappings, err := structureFromStruct(structType)
 	if err != nil {
 		return Decoder{}, err
 	}
+	mappings, d.extra = splitExtraField(mappings)
 
 	// ensure that all "unknown" types have their own text unmarshaler
 	for _, m := range mappings {
@@ -42,165 +152,456 @@ func NewDecoderFromCSVReader(csvR *csv.Reader, dest interface{}) (Decoder, error
 		}
 	}
 
-	headers, err := csvR.Read()
-	if err != nil {
-		return Decoder{}, fmt.Errorf("failed to find headers: %s", err)
+	headers := d.headers
+	if headers == nil {
+		if headers, err = csvR.Read(); err != nil {
+			return Decoder{}, fmt.Errorf("failed to find headers: %w", err)
+		}
+	}
+
+	// normalize every field name once, so each CSV header is matched with a single lookup
+	fieldsByHeader := make(map[string]int, len(mappings))
+	for i, f := range mappings {
+		for _, name := range f.names() {
+			key := d.normalize(name)
+			// fields that normalize to the same key could never be told apart
+			if prev, ok := fieldsByHeader[key]; ok && prev != i {
+				prevName := mappings[prev].fieldName
+				for _, n := range mappings[prev].names() {
+					if d.normalize(n) == key {
+						prevName = n
+						break
+					}
+				}
+				return Decoder{}, fmt.Errorf("csv field names '%s' and '%s' both normalize to header '%s'",
+					prevName, name, key)
+			}
+			fieldsByHeader[key] = i
+		}
 	}
 
 	allEmpty := true
 	numColumns := len(headers)
 	sortedMappings := make([]csvField, numColumns)
-	extraHeaders := []string{} // TODO: do anything with this?
-	headersSeen := map[string]bool{}
+	extraHeaders := []string{}
+	headersSeen := make(map[string]bool, numColumns)
+	// fieldColumns records which column each field was matched to, or -1
+	fieldColumns := make([]int, len(mappings))
+	for i := range fieldColumns {
+		fieldColumns[i] = -1
+	}
 	// Sort headers in line w/ CSV columns
-	for i, h := range headers {
-		h = normalizeHeader(h)
+	for i, rawHeader := range headers {
+		h := d.normalize(rawHeader)
 		// ensure unique CSV headers
 		if headersSeen[h] {
-			return Decoder{}, fmt.Errorf("saw header column '%s' twice, CSV headers must be unique", h)
+			return Decoder{}, fmt.Errorf("saw header column '%s' twice: %w", h, ErrDuplicateHeader)
 		}
 		headersSeen[h] = true
 
 		// slot field info in array parallel to CSV column
-		for _, f := range mappings {
-			if h == normalizeHeader(f.fieldName) {
-				sortedMappings[i] = f
+		if fieldIndex, ok := fieldsByHeader[h]; ok {
+			// two aliases of the same field must not both appear
+			if prev := fieldColumns[fieldIndex]; prev >= 0 {
+				return Decoder{}, fmt.Errorf("saw header columns '%s' and '%s' for field '%s': %w",
+					headers[prev], rawHeader, mappings[fieldIndex].fieldName, ErrDuplicateHeader)
 			}
-		}
-		// check if field not set
-		if sortedMappings[i].fieldName == "" {
-			extraHeaders = append(extraHeaders, h)
-		} else {
+			sortedMappings[i] = mappings[fieldIndex]
+			fieldColumns[fieldIndex] = i
 			// note that a field exists without an empty name
 			allEmpty = false
+		} else {
+			extraHeaders = append(extraHeaders, rawHeader)
 		}
 	}
 
-	// Ensure that at least one mapping has a non-empty field name
-	if allEmpty {
+	// Ensure that at least one mapping has a non-empty field name, unless unmatched
+	// columns are collected into an extra field
+	if allEmpty && d.extra == nil {
 		return Decoder{}, fmt.Errorf("all struct fields do not match any CSV headers")
 	}
 
+	if d.strict && d.extra == nil && len(extraHeaders) > 0 {
+		quoted := make([]string, len(extraHeaders))
+		for i, h := range extraHeaders {
+			quoted[i] = fmt.Sprintf("'%s'", h)
+		}
+		return Decoder{}, fmt.Errorf("%w: %s", ErrUnknownHeader, strings.Join(quoted, ", "))
+	}
+
 	// Ensure that all required columns are present
-	for _, f := range mappings {
-		if f.required && !headersSeen[normalizeHeader(f.fieldName)] {
-			return Decoder{}, fmt.Errorf("column '%s' required but not found", f.fieldName)
+	unmappedFields := []string{}
+	for i, f := range mappings {
+		if fieldColumns[i] >= 0 {
+			continue
 		}
+		if f.required {
+			return Decoder{}, fmt.Errorf("column '%s': %w", f.fieldName, ErrMissingColumn)
+		}
+		unmappedFields = append(unmappedFields, f.fieldName)
 	}
 
-	return Decoder{
-		r:          csvR,
-		mappings:   sortedMappings,
-		numColumns: numColumns,
-	}, nil
-}
+	for i, f := range mappings {
+		if f.defaultValue == "" {
+			continue
+		}
+		t := structType.FieldByIndex(f.fieldIndex).Type
+		decode, err := newFieldDecoder(t, f)
+		if err != nil {
+			return Decoder{}, err
+		}
+		if err := checkDefault(decode, t, f); err != nil {
+			return Decoder{}, fmt.Errorf("invalid default value for field '%s': %w", f.fieldName, err)
+		}
+		if fieldColumns[i] < 0 {
+			d.missingDefaults = append(d.missingDefaults, missingDefault{field: f, decode: decode})
+		}
+	}
 
-// normalizeHeader lowercases, trims whitespace and removes non-ascii characters
-func normalizeHeader(header string) string {
-	re := regexp.MustCompile("[[:^ascii:]]")
-	return strings.ToLower(strings.TrimSpace(re.ReplaceAllLiteralString(header, "")))
+	d.structType = structType
+	d.decoders = make([]fieldDecoder, numColumns)
+	for i, m := range sortedMappings {
+		if m.fieldName == "" {
+			continue
+		}
+		if d.decoders[i], err = newFieldDecoder(d.structType.FieldByIndex(m.fieldIndex).Type, m); err != nil {
+			return Decoder{}, err
+		}
+	}
+
+	d.mappings = sortedMappings
+	d.numColumns = numColumns
+	d.headers = append([]string{}, headers...)
+	d.extraHeaders = extraHeaders
+	d.unmappedFields = unmappedFields
+	return d, nil
 }
 
 // Read decodes data from a CSV row into a struct. The struct must be passed as a pointer
 // into Read.
-// When there is no data left in the reader, an `io.EOF` is returned.
+// When there is no data left in the reader, an `io.EOF` is returned. Failures to decode
+// the row are returned as a *DecodeError, or as a *RowError if the Decoder was created
+// with CollectErrors.
 func (d Decoder) Read(dest interface{}) error {
 	destStruct := reflect.ValueOf(dest)
 	if dest == nil {
 		return fmt.Errorf("Destination struct passed in cannot be nil")
 	} else if destStruct.Type().Kind() != reflect.Ptr {
 		return fmt.Errorf("Destination struct passed in must be pointer")
+	} else if destStruct.IsNil() {
+		return fmt.Errorf("Destination struct passed in cannot be a nil pointer")
 	} else if destStruct.Elem().Kind() == reflect.Interface {
 		return fmt.Errorf("Destination struct cannot be an interface")
+	} else if destStruct.Elem().Type() != d.structType {
+		return fmt.Errorf("Destination struct must be a %s, found %s", d.structType, destStruct.Elem().Type())
 	}
+	return d.read(destStruct.Elem())
+}
 
+// read decodes the next CSV row into destStruct, which must be an addressable value of
+// the struct type the Decoder was created with.
+func (d Decoder) read(destStruct reflect.Value) error {
 	row, err := d.r.Read()
 	if err == io.EOF {
 		return io.EOF
-	} else if err != nil {
-		return fmt.Errorf("failed to read CSV row: %s", err)
+	} else if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+		// the csv.Reader still returns the row on a field count mismatch, which is
+		// reported below along with the line it occurred on
+		return fmt.Errorf("failed to read CSV row: %w", err)
 	}
 
 	if len(row) != d.numColumns {
-		return fmt.Errorf("expected %d columns, found %d", d.numColumns, len(row))
+		line, _ := d.r.FieldPos(0)
+		return &DecodeError{
+			Line:   line,
+			Column: -1,
+			Err:    fmt.Errorf("%w: expected %d, found %d", ErrColumnCount, d.numColumns, len(row)),
+		}
 	}
 
+	// a fresh map is allocated for every row so rows never share their extra columns
+	var extraValues reflect.Value
+	if d.extra != nil {
+		extraField := fieldByIndexAlloc(destStruct, d.extra.fieldIndex)
+		extraValues = reflect.MakeMapWithSize(extraField.Type(), len(d.extraHeaders))
+		extraField.Set(extraValues)
+	}
+
+	var rowErr *RowError
 	for i, strValue := range row {
 		strValue = strings.TrimSpace(strValue)
 		m := d.mappings[i]
-		// skip column if we have no mapping
+		// skip column if we have no mapping, unless it is collected into the extra field
 		if m.fieldName == "" {
+			if d.extra != nil {
+				extraValues.SetMapIndex(
+					reflect.ValueOf(strings.TrimSpace(d.headers[i])).Convert(extraValues.Type().Key()),
+					reflect.ValueOf(strValue).Convert(extraValues.Type().Elem()))
+			}
 			continue
 		}
+		var err error
 		if strValue == "" {
-			if m.required {
-				return fmt.Errorf("column %s required but no value found", m.fieldName)
+			if m.notEmpty {
+				err = ErrMissingValue
+			} else if m.defaultValue != "" {
+				err = d.decoders[i](fieldByIndexAlloc(destStruct, m.fieldIndex), m.defaultValue)
+			} else if field, fieldErr := destStruct.FieldByIndexErr(m.fieldIndex); fieldErr == nil {
+				// an empty cell leaves a nil embedded or inline struct pointer nil, as
+				// there is nothing to store in it
+				field.SetZero()
+			}
+		} else {
+			field := fieldByIndexAlloc(destStruct, m.fieldIndex)
+			err = d.decoders[i](field, strValue)
+			if err == nil && m.rules != nil {
+				err = validate(field, m.rules)
+			}
+		}
+		if err != nil {
+			line, _ := d.r.FieldPos(i)
+			decodeErr := &DecodeError{
+				Line:   line,
+				Column: i,
+				Header: d.headers[i],
+				Field:  m.fieldName,
+				Value:  strValue,
+				Err:    err,
+			}
+			if !d.collectErrors {
+				return decodeErr
 			}
+			if rowErr == nil {
+				line, _ := d.r.FieldPos(0)
+				rowErr = &RowError{Line: line}
+			}
+			rowErr.Errors = append(rowErr.Errors, decodeErr)
+		}
+	}
 
-			v := destStruct.Elem().Field(m.fieldIndex)
-			v.Set(reflect.Zero(v.Type()))
-			continue
+	for _, def := range d.missingDefaults {
+		// defaults are checked by NewDecoder, so this only fails if the field's
+		// own unmarshaler is inconsistent
+		if err := def.decode(fieldByIndexAlloc(destStruct, def.field.fieldIndex), def.field.defaultValue); err != nil {
+			line, _ := d.r.FieldPos(0)
+			return &DecodeError{
+				Line:   line,
+				Column: -1,
+				Field:  def.field.fieldName,
+				Value:  def.field.defaultValue,
+				Err:    err,
+			}
 		}
+	}
+
+	if rowErr != nil {
+		return rowErr
+	}
+	return nil
+}
+
+// checkDefault decodes and validates the default value of a field of type t, so that
+// invalid defaults are reported by NewDecoder rather than on every Read.
+func checkDefault(decode fieldDecoder, t reflect.Type, f csvField) error {
+	v := reflect.New(t).Elem()
+	if err := decode(v, f.defaultValue); err != nil {
+		return err
+	}
+	return validate(v, f.rules)
+}
 
-		if m.customUnmarshaler {
-			v := destStruct.Elem().Field(m.fieldIndex)
-			if v.Type().Kind() != reflect.Ptr {
-				// if value is not a pointer we need an addressable value for Unmarshal
-				v = v.Addr()
-			} else if v.IsNil() {
-				// If the value is a pointer, but is nil, instantiate the underlying type
+// fieldByIndexAlloc returns the field of v at index, allocating any nil pointers to
+// embedded or inline structs on the way to it.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
 				v.Set(reflect.New(v.Type().Elem()))
 			}
-			u := v.Interface().(encoding.TextUnmarshaler)
-			if err := u.UnmarshalText([]byte(strValue)); err != nil {
-				return fmt.Errorf("failed to coerce value '%s' using custom marshaler for field %s: %s",
-					strValue, m.fieldName, err)
-			}
-			continue
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// fieldDecoder converts a non-empty CSV value and stores it in a struct field.
+type fieldDecoder func(field reflect.Value, strValue string) error
+
+// newFieldDecoder compiles the conversion for a mapped field of type t once, so that Read
+// does not need to inspect the field for every cell it decodes.
+func newFieldDecoder(t reflect.Type, m csvField) (fieldDecoder, error) {
+	if m.customUnmarshaler {
+		return decodeUnmarshaler, nil
+	}
+	if m.pointer {
+		elemType := t.Elem()
+		decodeElem, err := newKindDecoder(elemType, m)
+		if err != nil {
+			return nil, err
 		}
+		return func(field reflect.Value, strValue string) error {
+			// decode into a freshly allocated value so nullable fields never share storage
+			v := reflect.New(elemType).Elem()
+			if err := decodeElem(v, strValue); err != nil {
+				return err
+			}
+			field.Set(v.Addr())
+			return nil
+		}, nil
+	}
+	return newKindDecoder(t, m)
+}
 
-		switch m.fieldType {
-		case reflect.String:
-			destStruct.Elem().Field(m.fieldIndex).SetString(strValue)
-		case reflect.Int:
-			intVal, err := strconv.Atoi(strValue)
+// decodeUnmarshaler decodes a value into a field implementing encoding.TextUnmarshaler.
+func decodeUnmarshaler(field reflect.Value, strValue string) error {
+	v := field
+	if v.Type().Kind() != reflect.Ptr {
+		// if value is not a pointer we need an addressable value for Unmarshal
+		v = v.Addr()
+	} else if v.IsNil() {
+		// If the value is a pointer, but is nil, instantiate the underlying type
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+	u, ok := v.Interface().(encoding.TextUnmarshaler)
+	if !ok {
+		return fmt.Errorf("%s does not implement encoding.TextUnmarshaler", v.Type())
+	}
+	if err := u.UnmarshalText([]byte(strValue)); err != nil {
+		return fmt.Errorf("failed to coerce value '%s' using custom marshaler: %w", strValue, err)
+	}
+	return nil
+}
+
+// newKindDecoder compiles the conversion of a value into a field of the (non-pointer)
+// type t, based on the kind recorded in its mapping. Named types are supported as long as
+// their underlying kind is.
+func newKindDecoder(t reflect.Type, m csvField) (fieldDecoder, error) {
+	if t == durationType {
+		return func(v reflect.Value, strValue string) error {
+			d, err := time.ParseDuration(strValue)
+			if err != nil {
+				return fmt.Errorf("failed to coerce value '%s' into duration: %w", strValue, err)
+			}
+			v.SetInt(int64(d))
+			return nil
+		}, nil
+	}
+	switch m.fieldType {
+	case reflect.String:
+		return func(v reflect.Value, strValue string) error {
+			v.SetString(strValue)
+			return nil
+		}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		bits := t.Bits()
+		return func(v reflect.Value, strValue string) error {
+			intVal, err := strconv.ParseInt(strValue, 10, bits)
+			if err != nil {
+				return coerceError(strValue, "integer", t, err)
+			}
+			v.SetInt(intVal)
+			return nil
+		}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		bits := t.Bits()
+		return func(v reflect.Value, strValue string) error {
+			uintVal, err := strconv.ParseUint(strValue, 10, bits)
+			if err != nil {
+				return coerceError(strValue, "unsigned integer", t, err)
+			}
+			v.SetUint(uintVal)
+			return nil
+		}, nil
+	case reflect.Float32, reflect.Float64:
+		bits := t.Bits()
+		return func(v reflect.Value, strValue string) error {
+			floatVal, err := strconv.ParseFloat(strValue, bits)
 			if err != nil {
-				return fmt.Errorf("failed to coerce value '%s' into integer for field %s",
-					strValue, m.fieldName)
+				return coerceError(strValue, "float", t, err)
 			}
-			destStruct.Elem().Field(m.fieldIndex).SetInt(int64(intVal))
-		case reflect.Bool:
+			v.SetFloat(floatVal)
+			return nil
+		}, nil
+	case reflect.Bool:
+		return func(v reflect.Value, strValue string) error {
 			boolVal, err := strconv.ParseBool(strValue)
 			if err != nil {
-				return fmt.Errorf("failed to coerce value '%s' into boolean for field %s",
-					strValue, m.fieldName)
-			}
-			destStruct.Elem().Field(m.fieldIndex).SetBool(boolVal)
-		case reflect.Slice:
-			arrayStrValues := strings.Split(strValue, ",")
-			switch m.sliceType {
-			case reflect.String:
-				destStruct.Elem().Field(m.fieldIndex).Set(reflect.ValueOf(arrayStrValues))
-			case reflect.Int:
-				arrayIntValues := make([]int, len(arrayStrValues))
-				for i, s := range arrayStrValues {
-					intVal, err := strconv.Atoi(s)
-					if err != nil {
-						return fmt.Errorf("failed to coerce value '%s' (indexed %d) into integer for field %s: %s",
-							s, i, m.fieldName, err)
-					}
-					arrayIntValues[i] = int(intVal)
+				return fmt.Errorf("failed to coerce value '%s' into boolean: %w", strValue, err)
+			}
+			v.SetBool(boolVal)
+			return nil
+		}, nil
+	case reflect.Struct:
+		return func(v reflect.Value, strValue string) error {
+			t, err := parseTime(strValue, m.layouts, m.location)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(t))
+			return nil
+		}, nil
+	case reflect.Slice:
+		elemType := t.Elem()
+		elem := m.elemField(elemType)
+		if elem.fieldType == reflect.Invalid && !elem.customUnmarshaler {
+			return nil, fmt.Errorf("unsupported slice element type %s for field %s", elemType, m.fieldName)
+		}
+		decodeElem, err := newFieldDecoder(elemType, elem)
+		if err != nil {
+			return nil, err
+		}
+		sep := m.sliceSeparator()
+		return func(v reflect.Value, strValue string) error {
+			elems := splitEscaped(strValue, sep)
+			slice := reflect.MakeSlice(t, len(elems), len(elems))
+			for i, s := range elems {
+				if err := decodeElem(slice.Index(i), s); err != nil {
+					return fmt.Errorf("slice element %d: %w", i, err)
 				}
-				destStruct.Elem().Field(m.fieldIndex).Set(reflect.ValueOf(arrayIntValues))
-			default:
-				panic("slice fields can only be string.")
 			}
+			v.Set(slice)
+			return nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported field type %s for field %s", t, m.fieldName)
+	}
+}
+
+// splitEscaped splits a slice cell on every separator that is not escaped by a backslash.
+// A backslash escaping a character of the separator or another backslash is removed,
+// while any other backslash is kept as is.
+func splitEscaped(s, sep string) []string {
+	if !strings.Contains(s, `\`) {
+		return strings.Split(s, sep)
+	}
+	var elems []string
+	var elem strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i+1:])
+		switch {
+		case s[i] == '\\' && size > 0 && (r == '\\' || strings.ContainsRune(sep, r)):
+			elem.WriteString(s[i+1 : i+1+size])
+			i += 1 + size
+		case strings.HasPrefix(s[i:], sep):
+			elems = append(elems, elem.String())
+			elem.Reset()
+			i += len(sep)
 		default:
-			panic(fmt.Sprintf("type not found: %s", m.fieldType))
+			elem.WriteByte(s[i])
+			i++
 		}
 	}
+	return append(elems, elem.String())
+}
 
-	return nil
+// coerceError describes a failure to parse a CSV value into a numeric field, calling out
+// values that are syntactically valid but do not fit in the field's type.
+func coerceError(strValue, kind string, t reflect.Type, err error) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return fmt.Errorf("value '%s' overflows %s: %w", strValue, t, strconv.ErrRange)
+	}
+	return fmt.Errorf("failed to coerce value '%s' into %s: %w", strValue, kind, err)
 }
 
 // MatchedHeaders returns an array of strings (headers) using the Decoder mappings created
@@ -216,3 +617,22 @@ func (d Decoder) MatchedHeaders() []string {
 	}
 	return matchedHeaders
 }
+
+// Headers returns the header row exactly as it was read from the CSV.
+func (d Decoder) Headers() []string {
+	return append([]string{}, d.headers...)
+}
+
+// UnmatchedHeaders returns the CSV headers, as they were read from the CSV, that did not
+// match any struct field. Read ignores these columns, or collects them into the struct's
+// extra field if it has one. Returns an empty array when every header is matched.
+func (d Decoder) UnmatchedHeaders() []string {
+	return append([]string{}, d.extraHeaders...)
+}
+
+// UnmappedFields returns the names of the optional struct fields that had no matching
+// CSV column, and are therefore never set by Read other than to their default values.
+// Returns an empty array when every field is matched.
+func (d Decoder) UnmappedFields() []string {
+	return append([]string{}, d.unmappedFields...)
+}
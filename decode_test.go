@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -88,7 +90,7 @@ func TestNewDecoder(t *testing.T) {
 				StrField     string `csv:"string"`
 			}{},
 			csvFile: "string\ntest\ntest2",
-			err:     fmt.Errorf("column 'integer' required but not found"),
+			err:     fmt.Errorf("column 'integer': %w", ErrMissingColumn),
 		},
 		{
 			msg: "error when csv file has header twice",
@@ -96,7 +98,7 @@ func TestNewDecoder(t *testing.T) {
 				StrField string `csv:"string"`
 			}{},
 			csvFile: "string,test,string\n",
-			err:     fmt.Errorf("saw header column 'string' twice, CSV headers must be unique"),
+			err:     fmt.Errorf("saw header column 'string' twice: %w", ErrDuplicateHeader),
 		},
 		{
 			msg: "error when csv field has no UnmarshalText implementation",
@@ -252,7 +254,15 @@ func TestDecoderRead(t *testing.T) {
 		{
 			msg:     "invalid int array",
 			csvFile: "intarray\n\"1,a\"\n",
-			err:     errors.New("failed to coerce value 'a' (indexed 1) into integer for field intarray: strconv.Atoi: parsing \"a\": invalid syntax"),
+			err: &DecodeError{
+				Line:   2,
+				Column: 0,
+				Header: "intarray",
+				Field:  "intarray",
+				Value:  "1,a",
+				Err: fmt.Errorf("slice element 1: %w", fmt.Errorf("failed to coerce value 'a' into integer: %w",
+					&strconv.NumError{Func: "ParseInt", Num: "a", Err: strconv.ErrSyntax})),
+			},
 		},
 		{
 			msg:     "time: custom unmarshaler type",
@@ -323,13 +333,13 @@ func TestDecoderReadRequiredMissing(t *testing.T) {
 			msg:     "required string column missing value",
 			s:       S{},
 			csvFile: "string,extra,ptr\n,,test1\ntest2,,test3", // missing in first row!
-			err:     fmt.Errorf("column string required but no value found"),
+			err:     &DecodeError{Line: 2, Column: 0, Header: "string", Field: "string", Err: ErrMissingValue},
 		},
 		{
 			msg:     "required ptr column missing value",
 			s:       S{},
 			csvFile: "ptr,string\n,test1\ntest2,test3", // missing in first row!
-			err:     fmt.Errorf("column ptr required but no value found"),
+			err:     &DecodeError{Line: 2, Column: 0, Header: "ptr", Field: "ptr", Err: ErrMissingValue},
 		},
 	}
 
@@ -456,3 +466,543 @@ func TestDecoderMatchedHeaders(t *testing.T) {
 		})
 	}
 }
+
+func TestDecoderReadNumeric(t *testing.T) {
+	type S struct {
+		Int8    int8    `csv:"int8"`
+		Int64   int64   `csv:"int64"`
+		Uint    uint    `csv:"uint"`
+		Uint16  uint16  `csv:"uint16"`
+		Float32 float32 `csv:"float32"`
+		Float64 float64 `csv:"float64"`
+	}
+
+	specs := []struct {
+		msg     string
+		res     S
+		csvFile string
+		err     error
+	}{
+		{
+			msg:     "all numeric kinds",
+			csvFile: "int8,int64,uint,uint16,float32,float64\n-128,9223372036854775807,42,65535,1.5,-2.25\n",
+			res: S{
+				Int8:    -128,
+				Int64:   9223372036854775807,
+				Uint:    42,
+				Uint16:  65535,
+				Float32: 1.5,
+				Float64: -2.25,
+			},
+		},
+		{
+			msg:     "signed overflow",
+			csvFile: "int8\n128\n",
+			err:     errors.New("line 2, column 'int8': value '128' overflows int8: value out of range"),
+		},
+		{
+			msg:     "unsigned overflow",
+			csvFile: "uint16\n65536\n",
+			err:     errors.New("line 2, column 'uint16': value '65536' overflows uint16: value out of range"),
+		},
+		{
+			msg:     "negative unsigned",
+			csvFile: "uint\n-1\n",
+			err: errors.New("line 2, column 'uint': failed to coerce value '-1' into unsigned integer: " +
+				"strconv.ParseUint: parsing \"-1\": invalid syntax"),
+		},
+		{
+			msg:     "float overflow",
+			csvFile: "float32\n1e39\n",
+			err:     errors.New("line 2, column 'float32': value '1e39' overflows float32: value out of range"),
+		},
+		{
+			msg:     "invalid float",
+			csvFile: "float64\nabc\n",
+			err: errors.New("line 2, column 'float64': failed to coerce value 'abc' into float: " +
+				"strconv.ParseFloat: parsing \"abc\": invalid syntax"),
+		},
+	}
+
+	for _, s := range specs {
+		t.Run(s.msg, func(t *testing.T) {
+			d, err := NewDecoder(strings.NewReader(s.csvFile), S{})
+			assert.Nil(t, err, s.msg)
+			var val S
+			err = d.Read(&val)
+			if s.err != nil {
+				assert.EqualError(t, err, s.err.Error(), s.msg)
+				assert.ErrorAs(t, err, new(*DecodeError), s.msg)
+			} else if assert.NoError(t, err, s.msg) {
+				assert.Equal(t, s.res, val, s.msg)
+			}
+		})
+	}
+}
+
+func TestDecoderReadPointerFields(t *testing.T) {
+	type S struct {
+		Str   *string  `csv:"string"`
+		Int   *int     `csv:"integer"`
+		Bool  *bool    `csv:"boolean"`
+		Float *float64 `csv:"float"`
+	}
+	str, i, b, f := "x", 0, false, 1.5
+
+	d, err := NewDecoder(strings.NewReader("string,integer,boolean,float\nx,0,false,1.5\n,,,\n"), S{})
+	assert.NoError(t, err)
+
+	var s S
+	assert.NoError(t, d.Read(&s))
+	assert.Equal(t, S{Str: &str, Int: &i, Bool: &b, Float: &f}, s)
+
+	// a row of empty cells resets every field to nil
+	assert.NoError(t, d.Read(&s))
+	assert.Equal(t, S{}, s)
+}
+
+func TestDecoderUnmatchedHeadersAndFields(t *testing.T) {
+	type S struct {
+		Name  string `csv:"name,required"`
+		Email string `csv:"email"`
+		Phone string `csv:"phone"`
+	}
+
+	d, err := NewDecoder(strings.NewReader(" Name ,Emial,phone,Notes\n"), S{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{" Name ", "Emial", "phone", "Notes"}, d.Headers())
+	assert.Equal(t, []string{"Emial", "Notes"}, d.UnmatchedHeaders())
+	assert.Equal(t, []string{"email"}, d.UnmappedFields())
+
+	d, err = NewDecoder(strings.NewReader("name,email,phone\n"), S{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, d.UnmatchedHeaders())
+	assert.Equal(t, []string{}, d.UnmappedFields())
+}
+
+func TestNewDecoderDisallowUnknownHeaders(t *testing.T) {
+	type S struct {
+		Name  string `csv:"name"`
+		Email string `csv:"email"`
+	}
+
+	_, err := NewDecoder(strings.NewReader("name,Emial,email,Notes\n"), S{}, DisallowUnknownHeaders())
+	assert.Equal(t, fmt.Errorf("%w: 'Emial', 'Notes'", ErrUnknownHeader), err)
+	assert.ErrorIs(t, err, ErrUnknownHeader)
+
+	_, err = NewDecoder(strings.NewReader("name\n"), S{}, DisallowUnknownHeaders())
+	assert.NoError(t, err, "missing optional columns are still allowed")
+}
+
+func TestDecoderReadExtraField(t *testing.T) {
+	type S struct {
+		Name  string            `csv:"name"`
+		Extra map[string]string `csv:",extra"`
+	}
+
+	d, err := NewDecoder(strings.NewReader("name, Notes ,source\nx,hello,\ny,,web\n"), S{},
+		DisallowUnknownHeaders())
+	assert.NoError(t, err)
+
+	var first, second S
+	assert.NoError(t, d.Read(&first))
+	assert.Equal(t, S{Name: "x", Extra: map[string]string{"Notes": "hello", "source": ""}}, first)
+	assert.NoError(t, d.Read(&second))
+	assert.Equal(t, S{Name: "y", Extra: map[string]string{"Notes": "", "source": "web"}}, second)
+	assert.Equal(t, "hello", first.Extra["Notes"], "rows do not share their extra map")
+}
+
+func TestUnmarshal(t *testing.T) {
+	type S struct {
+		Name  string `csv:"name"`
+		Count int    `csv:"count"`
+	}
+	data := []byte("name,count\na,1\nb,2\n")
+
+	var rows []S
+	assert.NoError(t, Unmarshal(data, &rows))
+	assert.Equal(t, []S{{"a", 1}, {"b", 2}}, rows)
+
+	var ptrRows []*S
+	assert.NoError(t, Unmarshal(data, &ptrRows))
+	assert.Equal(t, []*S{{"a", 1}, {"b", 2}}, ptrRows)
+
+	rows = []S{{"old", 0}}
+	assert.NoError(t, Unmarshal([]byte("name,count\n"), &rows))
+	assert.Equal(t, []S{}, rows, "existing rows are replaced")
+
+	err := Unmarshal([]byte("name,count\na,x\n"), &rows)
+	assert.ErrorAs(t, err, new(*DecodeError))
+
+	assert.Equal(t, errors.New("Unmarshal destination must be a non-nil pointer to a slice"), Unmarshal(data, rows))
+	assert.Equal(t, errors.New("Unmarshal destination must be a slice of structs, found []string"),
+		Unmarshal(data, &[]string{}))
+}
+
+// wideStruct builds a struct type with n tagged string fields, along with a CSV header
+// row naming every field, to measure decoders for wide exports.
+func wideStruct(n int) (interface{}, string) {
+	fields := make([]reflect.StructField, n)
+	headers := make([]string, n)
+	for i := range fields {
+		headers[i] = fmt.Sprintf("Column Number %d", i)
+		fields[i] = reflect.StructField{
+			Name: fmt.Sprintf("Field%d", i),
+			Type: reflect.TypeOf(""),
+			Tag:  reflect.StructTag(fmt.Sprintf(`csv:"column number %d"`, i)),
+		}
+	}
+	return reflect.New(reflect.StructOf(fields)).Elem().Interface(), strings.Join(headers, ",") + "\n"
+}
+
+func BenchmarkNewDecoder(b *testing.B) {
+	for _, n := range []int{10, 400} {
+		dest, csvFile := wideStruct(n)
+		b.Run(fmt.Sprintf("%d columns", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := NewDecoder(strings.NewReader(csvFile), dest); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkNormalizeHeader(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		normalizeHeader("  Column Número 42 ")
+	}
+}
+
+func TestNormalizeHeader(t *testing.T) {
+	specs := map[string]string{
+		"  First Name ": "first name",
+		"Prénom":        "prnom",
+		"\300time":      "time",
+		"ID":            "id",
+	}
+	for header, expected := range specs {
+		assert.Equal(t, expected, normalizeHeader(header), header)
+	}
+}
+
+func BenchmarkDecoderRead(b *testing.B) {
+	type row struct {
+		Name    string   `csv:"name"`
+		Count   int      `csv:"count"`
+		Score   *float64 `csv:"score"`
+		ID      uint64   `csv:"id"`
+		Enabled bool     `csv:"enabled"`
+	}
+	var input strings.Builder
+	input.WriteString("name,count,score,id,enabled\n")
+	for i := 0; i < b.N; i++ {
+		fmt.Fprintf(&input, "name %d,%d,%d.5,%d,true\n", i, i, i, i)
+	}
+	d, err := NewDecoder(strings.NewReader(input.String()), row{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	var r row
+	for i := 0; i < b.N; i++ {
+		if err := d.Read(&r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type namedTags []string
+
+type namedInts []int
+
+func TestDecoderReadNamedTypes(t *testing.T) {
+	type S struct {
+		Tags namedTags `csv:"tags"`
+		IDs  namedInts `csv:"ids"`
+		Name noMarshal `csv:"name"`
+	}
+
+	d, err := NewDecoder(strings.NewReader("tags,ids,name\n\"a,b\",\"1,2\",x\n"), S{})
+	assert.NoError(t, err)
+	var s S
+	assert.NoError(t, d.Read(&s))
+	assert.Equal(t, S{Tags: namedTags{"a", "b"}, IDs: namedInts{1, 2}, Name: "x"}, s)
+}
+
+func TestDecoderReadInvalidDestination(t *testing.T) {
+	type S struct {
+		StrField string `csv:"string"`
+	}
+	type Other struct {
+		StrField string `csv:"string"`
+	}
+
+	d, err := NewDecoder(strings.NewReader("string\na\nb\n"), S{})
+	assert.NoError(t, err)
+	var nilPtr *S
+	assert.Equal(t, errors.New("Destination struct passed in cannot be a nil pointer"), d.Read(nilPtr))
+	assert.Equal(t, errors.New("Destination struct must be a csvutil.S, found csvutil.Other"), d.Read(&Other{}))
+}
+
+func TestDecoderUseHeaders(t *testing.T) {
+	type S struct {
+		ID   int    `csv:"id,required"`
+		Name string `csv:"name"`
+	}
+
+	d, err := NewDecoder(strings.NewReader("1,a,x\n2,b,y\n"), S{}, UseHeaders("id", "name", "other"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id", "name", "other"}, d.Headers())
+	assert.Equal(t, []string{"other"}, d.UnmatchedHeaders())
+
+	var s S
+	assert.NoError(t, d.Read(&s), "the first row is data")
+	assert.Equal(t, S{ID: 1, Name: "a"}, s)
+	assert.NoError(t, d.Read(&s))
+	assert.Equal(t, S{ID: 2, Name: "b"}, s)
+	assert.Equal(t, io.EOF, d.Read(&s))
+
+	_, err = NewDecoder(strings.NewReader("a\n"), S{}, UseHeaders("name"))
+	assert.ErrorIs(t, err, ErrMissingColumn)
+}
+
+func TestDecoderHeaderAliases(t *testing.T) {
+	type S struct {
+		Zip  string `csv:"zip|zipcode|postal_code,required"`
+		City string `csv:"city|town"`
+	}
+
+	for _, csvFile := range []string{"zip,city\n10001,nyc\n", "Postal_Code,TOWN\n10001,nyc\n"} {
+		d, err := NewDecoder(strings.NewReader(csvFile), S{})
+		assert.NoError(t, err, csvFile)
+		assert.Equal(t, []string{"zip", "city"}, d.MatchedHeaders(), csvFile)
+		var s S
+		assert.NoError(t, d.Read(&s), csvFile)
+		assert.Equal(t, S{Zip: "10001", City: "nyc"}, s, csvFile)
+	}
+
+	_, err := NewDecoder(strings.NewReader("zipcode,city,zip\n"), S{})
+	assert.Equal(t, fmt.Errorf("saw header columns 'zipcode' and 'zip' for field 'zip': %w", ErrDuplicateHeader), err)
+
+	_, err = NewDecoder(strings.NewReader("city\n"), S{})
+	assert.Equal(t, fmt.Errorf("column 'zip': %w", ErrMissingColumn), err)
+}
+
+func TestSplitEscaped(t *testing.T) {
+	specs := []struct {
+		value    string
+		sep      string
+		expected []string
+	}{
+		{value: "a,b,c", sep: ",", expected: []string{"a", "b", "c"}},
+		{value: "a", sep: ",", expected: []string{"a"}},
+		{value: `a\,b,c`, sep: ",", expected: []string{"a,b", "c"}},
+		{value: `a\\,b`, sep: ",", expected: []string{`a\`, "b"}},
+		{value: `a\\\,b`, sep: ",", expected: []string{`a\,b`}},
+		{value: `C:\dir|x`, sep: "|", expected: []string{`C:\dir`, "x"}},
+		{value: `a\; b; c`, sep: "; ", expected: []string{"a; b", "c"}},
+		{value: `a\|||b`, sep: "||", expected: []string{"a|", "b"}},
+		{value: `a||\|b`, sep: "||", expected: []string{"a", "|b"}},
+		{value: "a,,b,", sep: ",", expected: []string{"a", "", "b", ""}},
+	}
+	for _, spec := range specs {
+		assert.Equal(t, spec.expected, splitEscaped(spec.value, spec.sep), spec.value)
+	}
+}
+
+func TestDecoderSliceElements(t *testing.T) {
+	type S struct {
+		Tags   []string        `csv:"tags,sep=|"`
+		Scores []float32       `csv:"scores,sep=; "`
+		Flags  []bool          `csv:"flags"`
+		Ranks  []fifty         `csv:"ranks"`
+		Days   []time.Time     `csv:"days,format=2006-01-02,sep=|"`
+		Waits  []time.Duration `csv:"waits"`
+		IDs    []uint8         `csv:"ids"`
+	}
+	csvFile := "tags,scores,flags,ranks,days,waits,ids\n" +
+		"a|b\\|c|d,1.5; 2,\"true,false\",\"50,fifty\",2024-03-10|2024-03-11,\"1s,1m\",\"1,255\"\n"
+	var rows []S
+	assert.NoError(t, Unmarshal([]byte(csvFile), &rows))
+	assert.Equal(t, []S{{
+		Tags:   []string{"a", "b|c", "d"},
+		Scores: []float32{1.5, 2},
+		Flags:  []bool{true, false},
+		Ranks:  []fifty{"50", "50"},
+		Days: []time.Time{
+			time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC),
+		},
+		Waits: []time.Duration{time.Second, time.Minute},
+		IDs:   []uint8{1, 255},
+	}}, rows)
+
+	err := Unmarshal([]byte("ids\n\"1,256\"\n"), &rows)
+	var decodeErr *DecodeError
+	if assert.ErrorAs(t, err, &decodeErr) {
+		assert.Equal(t, "ids", decodeErr.Header)
+		assert.ErrorIs(t, err, strconv.ErrRange)
+		assert.Contains(t, err.Error(), "slice element 1: value '256' overflows uint8")
+	}
+}
+
+func TestDecoderSliceOfMarshalersOnly(t *testing.T) {
+	_, err := NewDecoder(strings.NewReader("f1\n"), struct {
+		Field1 []marshalOnly `csv:"f1"`
+	}{})
+	assert.Equal(t, fmt.Errorf("unsupported slice element type csvutil.marshalOnly for field f1"), err)
+}
+
+type marshalOnly struct{}
+
+func (marshalOnly) MarshalText() ([]byte, error) {
+	return []byte("m"), nil
+}
+
+func TestDecoderNestedStructs(t *testing.T) {
+	type S struct {
+		ID string `csv:"id"`
+		*Audit
+		audit
+		Home *Address `csv:"home,inline"`
+		Work Address  `csv:"work,inline"`
+	}
+	csvFile := "id,created_by,source,home.street,home.town,work.street,work.city\n" +
+		"1,ada,import,1 Main St,Springfield,2 Side St,Shelbyville\n"
+	var rows []S
+	assert.NoError(t, Unmarshal([]byte(csvFile), &rows))
+	assert.Equal(t, []S{{
+		ID:    "1",
+		Audit: &Audit{CreatedBy: "ada"},
+		audit: audit{Source: "import"},
+		Home:  &Address{Street: "1 Main St", City: "Springfield"},
+		Work:  Address{Street: "2 Side St", City: "Shelbyville"},
+	}}, rows)
+
+	d, err := NewDecoder(strings.NewReader(csvFile), S{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"updated_by"}, d.UnmappedFields())
+}
+
+func TestDecoderNestedStructPointersStayNil(t *testing.T) {
+	type S struct {
+		ID string `csv:"id"`
+		*Audit
+		Home *Address `csv:"home,inline"`
+	}
+	in := []S{{ID: "1"}, {ID: "2", Home: &Address{City: "Springfield"}}}
+	data, err := Marshal(in)
+	assert.NoError(t, err)
+	assert.Equal(t, "id,created_by,updated_by,home.street,home.city\n1,,,,\n2,,,,Springfield\n", string(data))
+
+	// pointers are only allocated for rows with a non-empty value to store in them
+	var rows []S
+	assert.NoError(t, Unmarshal(data, &rows))
+	assert.Equal(t, in, rows)
+
+	// a struct reused across rows keeps the pointers allocated by earlier rows, but
+	// their fields are cleared
+	d, err := NewDecoder(strings.NewReader(string(data)), S{})
+	assert.NoError(t, err)
+	s := S{Audit: &Audit{CreatedBy: "ada"}}
+	assert.NoError(t, d.Read(&s))
+	assert.Equal(t, S{ID: "1", Audit: &Audit{}}, s)
+}
+
+func TestNewDecoderDestinationTypes(t *testing.T) {
+	type S struct {
+		Name string `csv:"name"`
+	}
+	for _, dest := range []interface{}{S{}, &S{}, []S{}, []*S{}, reflect.TypeOf(S{})} {
+		d, err := NewDecoder(strings.NewReader("name\nada\n"), dest)
+		if assert.NoError(t, err, "%T", dest) {
+			var s S
+			assert.NoError(t, d.Read(&s), "%T", dest)
+			assert.Equal(t, S{Name: "ada"}, s, "%T", dest)
+		}
+	}
+
+	_, err := NewDecoder(strings.NewReader("name\nada\n"), "name")
+	assert.Equal(t, fmt.Errorf("expected a struct, pointer to struct, slice of structs or reflect.Type, found string"), err)
+}
+
+func TestDecoderRequiredAndNotEmpty(t *testing.T) {
+	type S struct {
+		Both   string `csv:"both,required"`
+		Column string `csv:"column,required,allowempty"`
+		Value  string `csv:"value,notempty"`
+	}
+
+	var rows []S
+	assert.NoError(t, Unmarshal([]byte("both,column,value\na,,c\n"), &rows))
+	assert.Equal(t, []S{{Both: "a", Value: "c"}}, rows)
+
+	// the notempty column may be missing, but the required ones may not
+	assert.NoError(t, Unmarshal([]byte("both,column\na,\n"), &rows))
+	assert.Equal(t, []S{{Both: "a"}}, rows)
+	assert.Equal(t, fmt.Errorf("column 'column': %w", ErrMissingColumn), Unmarshal([]byte("both,value\na,c\n"), &rows))
+
+	for _, spec := range []struct {
+		csvFile string
+		header  string
+		column  int
+	}{
+		{csvFile: "both,column,value\n,b,c\n", header: "both", column: 0},
+		{csvFile: "both,column,value\na,b,\n", header: "value", column: 2},
+	} {
+		err := Unmarshal([]byte(spec.csvFile), &rows)
+		assert.Equal(t, &DecodeError{Line: 2, Column: spec.column, Header: spec.header, Field: spec.header, Err: ErrMissingValue}, err, spec.csvFile)
+	}
+}
+
+func TestDecoderDefaultValues(t *testing.T) {
+	type S struct {
+		Name    string   `csv:"name"`
+		Country string   `csv:"country,required,default=US"`
+		Count   *int     `csv:"count,default=1"`
+		Tags    []string `csv:"tags,default=a|b,sep=|"`
+	}
+	csvFile := "name,country\nada,\ngrace,UK\n"
+	var rows []*S
+	assert.NoError(t, Unmarshal([]byte(csvFile), &rows))
+	one := 1
+	assert.Equal(t, []*S{
+		{Name: "ada", Country: "US", Count: &one, Tags: []string{"a", "b"}},
+		{Name: "grace", Country: "UK", Count: &one, Tags: []string{"a", "b"}},
+	}, rows)
+	// defaults for missing columns are decoded for every row, so rows never share them
+	assert.NotSame(t, rows[0].Count, rows[1].Count)
+	rows[0].Tags[0] = "changed"
+	assert.Equal(t, []string{"a", "b"}, rows[1].Tags)
+
+	d, err := NewDecoder(strings.NewReader(csvFile), S{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"count", "tags"}, d.UnmappedFields())
+
+	// invalid defaults are reported whether or not their column is present
+	type invalid struct {
+		Name  string `csv:"name"`
+		Count int8   `csv:"count,default=300"`
+	}
+	for _, csvFile := range []string{"name\nada\n", "name,count\nada,1\n"} {
+		_, err = NewDecoder(strings.NewReader(csvFile), invalid{})
+		assert.Equal(t, fmt.Errorf("invalid default value for field 'count': %w",
+			fmt.Errorf("value '300' overflows int8: %w", strconv.ErrRange)), err, csvFile)
+	}
+
+	// a default does not make a required column optional
+	assert.Equal(t, fmt.Errorf("column 'country': %w", ErrMissingColumn), Unmarshal([]byte("name\nada\n"), &rows))
+
+	// empty cells decode the default, while other cells are decoded as usual
+	assert.NoError(t, Unmarshal([]byte("country,count\nFR,\nFR,3\n"), &rows))
+	three := 3
+	assert.Equal(t, []*S{
+		{Country: "FR", Count: &one, Tags: []string{"a", "b"}},
+		{Country: "FR", Count: &three, Tags: []string{"a", "b"}},
+	}, rows)
+}
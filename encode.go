@@ -1,38 +1,125 @@
 package csvutil
 
 import (
+	"bytes"
 	"encoding"
 	"encoding/csv"
 	"fmt"
 	"io"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
-// Encoder manages writing a tagged struct into a CSV
+// Encoder manages writing a tagged struct into a CSV. Rows are buffered by the underlying
+// csv.Writer: call Flush (or Close) once done writing, or create the Encoder with
+// FlushEachRow.
 type Encoder struct {
-	w        *csv.Writer
-	mu       *sync.Mutex
-	mappings []csvField
+	w          *csv.Writer
+	mu         *sync.Mutex
+	structType reflect.Type
+	mappings   []csvField
+	// encoders holds the compiled conversion for each mapping
+	encoders     []fieldEncoder
+	extra        *csvField
+	flushEachRow bool
+	skipHeader   bool
+	// state is shared between copies of the Encoder, and guarded by mu
+	state *encoderState
 }
 
-// NewEncoder prepares mappings from struct to CSV based on struct tags.
-func NewEncoder(w io.Writer, dest interface{}) (Encoder, error) {
+// encoderState holds the parts of an Encoder that change as rows are written.
+type encoderState struct {
+	// extraHeaders lists the columns written from the extra field, after the mapped ones.
+	// It is set when the first row is written.
+	extraHeaders []string
+	closed       bool
+}
+
+// EncoderOption configures optional behavior of an Encoder.
+type EncoderOption func(*Encoder)
+
+// FlushEachRow makes Write flush the underlying csv.Writer after every row, so each row
+// reaches the io.Writer as soon as it is written. This is much slower on unbuffered writers
+// such as an os.File or net.Conn.
+func FlushEachRow() EncoderOption {
+	return func(e *Encoder) {
+		e.flushEachRow = true
+	}
+}
+
+// Marshal encodes v, a slice of tagged structs or of pointers to them, into CSV data with
+// a header row.
+func Marshal(v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if v == nil || rv.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("Marshal source must be a slice")
+	}
+	structType := rv.Type().Elem()
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Marshal source must be a slice of structs, found %s", rv.Type())
+	}
+
+	var buf bytes.Buffer
+	e, err := NewEncoder(&buf, structType)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		row := rv.Index(i)
+		if row.Kind() == reflect.Ptr {
+			if row.IsNil() {
+				return nil, fmt.Errorf("Marshal source cannot contain nil elements, found one at index %d", i)
+			}
+			row = row.Elem()
+		}
+		if err := e.write(row); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SkipHeader makes the Encoder write rows without a header row, e.g. to append to an
+// existing CSV file.
+func SkipHeader() EncoderOption {
+	return func(e *Encoder) {
+		e.skipHeader = true
+	}
+}
+
+// NewEncoder prepares mappings from struct to CSV based on struct tags. dest describes
+// the struct type to write, and may be a struct, a pointer to a struct, a slice of
+// structs or a reflect.Type.
+// If the struct has an extra field, writing the headers is deferred to the first call to
+// Write, whose extra keys (sorted) become the trailing columns of the CSV.
+func NewEncoder(w io.Writer, dest interface{}, opts ...EncoderOption) (Encoder, error) {
 	csvW := csv.NewWriter(w)
-	return NewEncoderFromCSVWriter(csvW, dest)
+	return NewEncoderFromCSVWriter(csvW, dest, opts...)
 }
 
 // NewEncoderFromCSVWriter intializes an encoder using the given csv.Writer.
 // This allows the caller to configure options on the csv.Writer (e.g. what
 // delimiter to use) instead of using the defaults.
-func NewEncoderFromCSVWriter(csvW *csv.Writer, dest interface{}) (Encoder, error) {
-	mappings, err := structureFromStruct(dest)
+func NewEncoderFromCSVWriter(csvW *csv.Writer, dest interface{}, opts ...EncoderOption) (Encoder, error) {
+	structType, err := structTypeOf(dest)
+	if err != nil {
+		return Encoder{}, err
+	}
+	mappings, err := structureFromStruct(structType)
 	if err != nil {
 		return Encoder{}, err
 	}
-	defer csvW.Flush()
+	mappings, extra := splitExtraField(mappings)
 
 	// ensure that all "unknown" types have their own text marshaler
 	for _, m := range mappings {
@@ -42,20 +129,49 @@ func NewEncoderFromCSVWriter(csvW *csv.Writer, dest interface{}) (Encoder, error
 		}
 	}
 
-	headers := make([]string, len(mappings))
+	encoders := make([]fieldEncoder, len(mappings))
 	for i, m := range mappings {
-		headers[i] = m.fieldName
+		if encoders[i], err = newFieldEncoder(structType.FieldByIndex(m.fieldIndex).Type, m); err != nil {
+			return Encoder{}, err
+		}
 	}
 
-	if err = csvW.Write(headers); err != nil {
-		return Encoder{}, fmt.Errorf("failed to write headers: %s", err)
+	e := Encoder{
+		mu:         &sync.Mutex{},
+		w:          csvW,
+		structType: structType,
+		mappings:   mappings,
+		encoders:   encoders,
+		extra:      extra,
+		state:      &encoderState{},
 	}
+	for _, opt := range opts {
+		opt(&e)
+	}
+	if extra == nil {
+		if err = e.writeHeaders(); err != nil {
+			return Encoder{}, err
+		}
+	}
+	return e, nil
+}
 
-	return Encoder{
-		mu:       &sync.Mutex{},
-		w:        csvW,
-		mappings: mappings,
-	}, nil
+// writeHeaders writes the names of the mapped columns, followed by any extra columns,
+// unless the Encoder was created with SkipHeader.
+func (e Encoder) writeHeaders() error {
+	if e.skipHeader {
+		return nil
+	}
+	headers := make([]string, len(e.mappings), len(e.mappings)+len(e.state.extraHeaders))
+	for i, m := range e.mappings {
+		headers[i] = m.fieldName
+	}
+	headers = append(headers, e.state.extraHeaders...)
+
+	if err := e.w.Write(headers); err != nil {
+		return fmt.Errorf("failed to write headers: %s", err)
+	}
+	return nil
 }
 
 // Write encodes the values of a struct into a CSV row and writes to the underlying io.writer.
@@ -64,52 +180,253 @@ func (e Encoder) Write(src interface{}) error {
 	if src == nil {
 		return fmt.Errorf("Source struct passed in cannot be nil")
 	} else if srcStruct.Type().Kind() == reflect.Ptr {
+		if srcStruct.IsNil() {
+			return fmt.Errorf("Source struct passed in cannot be a nil pointer")
+		}
 		srcStruct = srcStruct.Elem()
 	}
+	if srcStruct.Type() != e.structType {
+		return fmt.Errorf("Source struct must be a %s, found %s", e.structType, srcStruct.Type())
+	}
+	return e.write(srcStruct)
+}
 
+// write encodes srcStruct, a value of the struct type the Encoder was created with, into a
+// CSV row.
+func (e Encoder) write(srcStruct reflect.Value) error {
 	rowValues := make([]string, len(e.mappings))
 	for i, m := range e.mappings {
-		v := srcStruct.Field(m.fieldIndex)
-
-		if m.customMarshaler {
-			u := v.Interface().(encoding.TextMarshaler)
-			buf, err := u.MarshalText()
-			if err != nil {
-				return fmt.Errorf("failed to coerce value '%s' into string using custom marshaler for field %s: %s",
-					v, m.fieldName, err)
-			}
-			rowValues[i] = string(buf)
+		v, err := srcStruct.FieldByIndexErr(m.fieldIndex)
+		if err != nil || (v.Kind() == reflect.Ptr && v.IsNil()) {
+			// nil pointers, including fields of nil embedded or inline structs, are written
+			// as empty cells
 			continue
 		}
+		strValue, err := e.encoders[i](v)
+		if err != nil {
+			return fmt.Errorf("failed to coerce value '%s' into string for field %s: %w", v, m.fieldName, err)
+		}
+		rowValues[i] = strValue
+	}
 
-		switch m.fieldType {
-		case reflect.String:
-			rowValues[i] = v.String()
-		case reflect.Int:
-			rowValues[i] = strconv.Itoa(int(v.Int()))
-		case reflect.Bool:
-			rowValues[i] = strconv.FormatBool(v.Bool())
-		case reflect.Slice:
-			switch m.sliceType {
-			case reflect.String:
-				rowValues[i] = strings.Join(v.Interface().([]string), ",")
-			case reflect.Int:
-				intArray := v.Interface().([]int)
-				strArray := make([]string, len(intArray))
-				for i, iVal := range intArray {
-					strArray[i] = strconv.Itoa(iVal)
-				}
-				rowValues[i] = strings.Join(strArray, ",")
-			default:
-				panic("slice fields can only be string.")
-			}
-		default:
-			panic(fmt.Sprintf("type not found: %s", m.fieldType))
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.state.closed {
+		return ErrEncoderClosed
+	}
+	if e.extra != nil {
+		extraField, err := srcStruct.FieldByIndexErr(e.extra.fieldIndex)
+		if err != nil {
+			// the extra field of a nil embedded struct has no columns to write
+			extraField = reflect.Zero(e.structType.FieldByIndex(e.extra.fieldIndex).Type)
+		}
+		extraValues, err := e.extraValues(extraField)
+		if err != nil {
+			return err
 		}
+		rowValues = append(rowValues, extraValues...)
+	}
+	if err := e.w.Write(rowValues); err != nil {
+		return err
 	}
+	if e.flushEachRow {
+		e.w.Flush()
+		return e.w.Error()
+	}
+	return nil
+}
 
+// Flush writes any buffered rows to the underlying io.Writer, and returns any error that
+// occurred while writing.
+func (e Encoder) Flush() error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	defer e.w.Flush()
-	return e.w.Write(rowValues)
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// Error returns any error that occurred while writing or flushing rows.
+func (e Encoder) Error() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.w.Error()
+}
+
+// Close flushes any buffered rows, and makes further calls to Write fail with
+// ErrEncoderClosed. If no row was written for a struct with an extra field, the headers
+// are written without any extra columns. Close does not close the underlying io.Writer.
+func (e Encoder) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.state.closed {
+		return nil
+	}
+	e.state.closed = true
+	if e.extra != nil && e.state.extraHeaders == nil {
+		e.state.extraHeaders = []string{}
+		if err := e.writeHeaders(); err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// fieldEncoder converts a (non-nil) struct field into a CSV value.
+type fieldEncoder func(v reflect.Value) (string, error)
+
+// newFieldEncoder compiles the conversion for a mapped field of type t once, so that Write
+// does not need to inspect the field for every cell it encodes.
+func newFieldEncoder(t reflect.Type, m csvField) (fieldEncoder, error) {
+	if m.customMarshaler {
+		return encodeMarshaler, nil
+	}
+	if m.pointer {
+		encodeElem, err := newKindEncoder(t.Elem(), m)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) (string, error) {
+			return encodeElem(v.Elem())
+		}, nil
+	}
+	return newKindEncoder(t, m)
+}
+
+// encodeMarshaler encodes a field implementing encoding.TextMarshaler.
+func encodeMarshaler(v reflect.Value) (string, error) {
+	if !v.Type().Implements(textMarshalerType) {
+		// the marshaler is implemented on the pointer type, which needs an addressable value
+		if !v.CanAddr() {
+			ptr := reflect.New(v.Type())
+			ptr.Elem().Set(v)
+			v = ptr.Elem()
+		}
+		v = v.Addr()
+	}
+	u, ok := v.Interface().(encoding.TextMarshaler)
+	if !ok {
+		return "", fmt.Errorf("%s does not implement encoding.TextMarshaler", v.Type())
+	}
+	buf, err := u.MarshalText()
+	if err != nil {
+		return "", fmt.Errorf("custom marshaler failed: %w", err)
+	}
+	return string(buf), nil
+}
+
+// newKindEncoder compiles the conversion of a field of the (non-pointer) type t into a
+// value, based on the kind recorded in its mapping. Named types are supported as long as
+// their underlying kind is.
+func newKindEncoder(t reflect.Type, m csvField) (fieldEncoder, error) {
+	if t == durationType {
+		return func(v reflect.Value) (string, error) {
+			return time.Duration(v.Int()).String(), nil
+		}, nil
+	}
+	switch m.fieldType {
+	case reflect.String:
+		return func(v reflect.Value) (string, error) {
+			return v.String(), nil
+		}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(v reflect.Value) (string, error) {
+			return strconv.FormatInt(v.Int(), 10), nil
+		}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(v reflect.Value) (string, error) {
+			return strconv.FormatUint(v.Uint(), 10), nil
+		}, nil
+	case reflect.Float32, reflect.Float64:
+		bits := t.Bits()
+		return func(v reflect.Value) (string, error) {
+			return strconv.FormatFloat(v.Float(), 'f', -1, bits), nil
+		}, nil
+	case reflect.Bool:
+		return func(v reflect.Value) (string, error) {
+			return strconv.FormatBool(v.Bool()), nil
+		}, nil
+	case reflect.Struct:
+		return func(v reflect.Value) (string, error) {
+			return formatTime(v.Interface().(time.Time), m.layouts[0], m.location), nil
+		}, nil
+	case reflect.Slice:
+		elemType := t.Elem()
+		elem := m.elemField(elemType)
+		if elem.fieldType == reflect.Invalid && !elem.customMarshaler {
+			return nil, fmt.Errorf("unsupported slice element type %s for field %s", elemType, m.fieldName)
+		}
+		encodeElem, err := newFieldEncoder(elemType, elem)
+		if err != nil {
+			return nil, err
+		}
+		sep := m.sliceSeparator()
+		// escape backslashes and every character of the separator within elements, so that
+		// they are split back into the same elements when decoding. Escaping only complete
+		// separators is not enough for longer ones: with "||", the element "a|" followed by
+		// a separator would be read back as "a" followed by "|b".
+		replacements := []string{`\`, `\\`}
+		for i, r := range sep {
+			if !strings.ContainsRune(sep[:i], r) {
+				replacements = append(replacements, string(r), `\`+string(r))
+			}
+		}
+		escaper := strings.NewReplacer(replacements...)
+		return func(v reflect.Value) (string, error) {
+			strArray := make([]string, v.Len())
+			for i := range strArray {
+				s, err := encodeElem(v.Index(i))
+				if err != nil {
+					return "", fmt.Errorf("slice element %d: %w", i, err)
+				}
+				strArray[i] = escaper.Replace(s)
+			}
+			return strings.Join(strArray, sep), nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported field type %s for field %s", t, m.fieldName)
+	}
+}
+
+// extraValues returns the cells for the extra columns of a row. The first row written
+// determines the extra columns and triggers writing the headers; keys of later rows must
+// be among those columns. Callers must hold e.mu.
+func (e Encoder) extraValues(v reflect.Value) ([]string, error) {
+	if e.state.extraHeaders == nil {
+		keys := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		slices.Sort(keys)
+		// an extra column named like a mapped one would write a duplicate header, which
+		// the Decoder rejects
+		for _, m := range e.mappings {
+			for _, name := range m.names() {
+				if slices.Contains(keys, name) {
+					return nil, fmt.Errorf("extra column '%s' has the same name as the column of field '%s'", name, m.fieldName)
+				}
+			}
+		}
+		e.state.extraHeaders = keys
+		if err := e.writeHeaders(); err != nil {
+			return nil, err
+		}
+	}
+
+	values := make([]string, len(e.state.extraHeaders))
+	found := 0
+	for i, h := range e.state.extraHeaders {
+		if val := v.MapIndex(reflect.ValueOf(h).Convert(v.Type().Key())); val.IsValid() {
+			values[i] = val.String()
+			found++
+		}
+	}
+	if found != v.Len() {
+		for _, k := range v.MapKeys() {
+			if !slices.Contains(e.state.extraHeaders, k.String()) {
+				return nil, fmt.Errorf("extra column '%s' is not among the headers written for the first row", k.String())
+			}
+		}
+	}
+	return values, nil
 }
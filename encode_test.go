@@ -5,6 +5,8 @@ import (
 	"encoding/csv"
 	"errors"
 	"fmt"
+	"io"
+	"reflect"
 	"testing"
 	"time"
 
@@ -18,8 +20,9 @@ func TestNewEncoderValid(t *testing.T) {
 	}
 	var buf bytes.Buffer
 
-	_, err := NewEncoder(&buf, valid{})
+	enc, err := NewEncoder(&buf, valid{})
 	assert.Nil(t, err)
+	assert.Nil(t, enc.Flush())
 	assert.Equal(t, "string,integer\n", buf.String())
 }
 
@@ -32,8 +35,9 @@ func TestNewEncoderFromCSVWriter(t *testing.T) {
 
 	w := csv.NewWriter(&buf)
 	w.Comma = '\t'
-	_, err := NewEncoderFromCSVWriter(w, valid{})
+	enc, err := NewEncoderFromCSVWriter(w, valid{})
 	assert.Nil(t, err)
+	assert.Nil(t, enc.Flush())
 	assert.Equal(t, "string\tinteger\n", buf.String())
 }
 
@@ -45,8 +49,9 @@ func TestNewEncoderMissingField(t *testing.T) {
 	}
 	var buf bytes.Buffer
 
-	_, err := NewEncoder(&buf, ignoredFieldStruct{})
+	enc, err := NewEncoder(&buf, ignoredFieldStruct{})
 	assert.Nil(t, err)
+	assert.Nil(t, enc.Flush())
 	assert.Equal(t, "string,integer\n", buf.String())
 }
 
@@ -82,6 +87,7 @@ func TestEncodeValid(t *testing.T) {
 	assert.Nil(t, err)
 	err = enc.Write(x)
 	assert.Nil(t, err)
+	assert.Nil(t, enc.Flush())
 	assert.Equal(t, "string,integer\nfoo,100\n", buf.String())
 }
 
@@ -101,6 +107,7 @@ func TestWriteWithMissingField(t *testing.T) {
 	assert.Nil(t, err)
 	err = enc.Write(x)
 	assert.Nil(t, err)
+	assert.Nil(t, enc.Flush())
 	assert.Equal(t, "string,integer\nfoo,100\n", buf.String())
 }
 
@@ -119,6 +126,7 @@ func TestWriteCustomMarshaler(t *testing.T) {
 	assert.Nil(t, err)
 	err = enc.Write(x)
 	assert.Nil(t, err)
+	assert.Nil(t, enc.Flush())
 	assert.Equal(t, fmt.Sprintf("string,time\nfoo,%s\n", defaultTimeStr), buf.String())
 
 	// test passing in a pointer
@@ -127,6 +135,7 @@ func TestWriteCustomMarshaler(t *testing.T) {
 	assert.Nil(t, err)
 	err = enc.Write(&x)
 	assert.Nil(t, err)
+	assert.Nil(t, enc.Flush())
 	assert.Equal(t, fmt.Sprintf("string,time\nfoo,%s\n", defaultTimeStr), buf.String())
 }
 
@@ -140,3 +149,456 @@ func TestWriteFailNil(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, enc.Write(nil), errors.New("Source struct passed in cannot be nil"))
 }
+
+func TestEncodeNumeric(t *testing.T) {
+	type numeric struct {
+		Int8    int8    `csv:"int8"`
+		Int64   int64   `csv:"int64"`
+		Uint64  uint64  `csv:"uint64"`
+		Float32 float32 `csv:"float32"`
+		Float64 float64 `csv:"float64"`
+	}
+	x := numeric{
+		Int8:    -8,
+		Int64:   1 << 40,
+		Uint64:  18446744073709551615,
+		Float32: 0.1,
+		Float64: 1234.5,
+	}
+	var buf bytes.Buffer
+
+	enc, err := NewEncoder(&buf, numeric{})
+	assert.Nil(t, err)
+	err = enc.Write(x)
+	assert.Nil(t, err)
+	assert.Nil(t, enc.Flush())
+	assert.Equal(t, "int8,int64,uint64,float32,float64\n-8,1099511627776,18446744073709551615,0.1,1234.5\n", buf.String())
+}
+
+func TestEncodePointerFields(t *testing.T) {
+	type nullable struct {
+		Str  *string    `csv:"string"`
+		Int  *int       `csv:"integer"`
+		Time *time.Time `csv:"time"`
+	}
+	str, i := "foo", 0
+	var buf bytes.Buffer
+
+	enc, err := NewEncoder(&buf, nullable{})
+	assert.Nil(t, err)
+	assert.Nil(t, enc.Write(nullable{Str: &str, Int: &i, Time: &defaultTime}))
+	assert.Nil(t, enc.Write(nullable{}))
+	assert.Nil(t, enc.Flush())
+	assert.Equal(t, fmt.Sprintf("string,integer,time\nfoo,0,%s\n,,\n", defaultTimeStr), buf.String())
+}
+
+func TestEncodeExtraField(t *testing.T) {
+	type withExtra struct {
+		Name  string            `csv:"name"`
+		Extra map[string]string `csv:",extra"`
+	}
+	var buf bytes.Buffer
+
+	enc, err := NewEncoder(&buf, withExtra{})
+	assert.Nil(t, err)
+	assert.Equal(t, "", buf.String(), "headers are deferred to the first row")
+
+	assert.Nil(t, enc.Write(withExtra{Name: "x", Extra: map[string]string{"source": "web", "notes": "hi"}}))
+	assert.Nil(t, enc.Write(withExtra{Name: "y", Extra: map[string]string{"notes": "bye"}}))
+	assert.Nil(t, enc.Write(withExtra{Name: "z"}))
+	assert.Nil(t, enc.Flush())
+	assert.Equal(t, "name,notes,source\nx,hi,web\ny,bye,\nz,,\n", buf.String())
+
+	err = enc.Write(withExtra{Name: "w", Extra: map[string]string{"other": "1"}})
+	assert.Equal(t, errors.New("extra column 'other' is not among the headers written for the first row"), err)
+}
+
+func TestEncodeExtraFieldNamedLikeMappedColumn(t *testing.T) {
+	type withExtra struct {
+		Name  string            `csv:"name|full_name"`
+		Extra map[string]string `csv:",extra"`
+	}
+	for _, key := range []string{"name", "full_name"} {
+		var buf bytes.Buffer
+		enc, err := NewEncoder(&buf, withExtra{})
+		assert.Nil(t, err)
+		err = enc.Write(withExtra{Name: "a", Extra: map[string]string{key: "x"}})
+		assert.Equal(t, fmt.Errorf("extra column '%s' has the same name as the column of field 'name'", key), err)
+		assert.Nil(t, enc.Close())
+		assert.Equal(t, "name\n", buf.String(), "the headers are not fixed by a rejected row")
+	}
+}
+
+func TestExtraFieldRoundTrip(t *testing.T) {
+	type withExtra struct {
+		Name  string            `csv:"name"`
+		Extra map[string]string `csv:",extra"`
+	}
+	input := "name,notes,source\nx,hi,web\ny,bye,\n"
+
+	d, err := NewDecoder(bytes.NewBufferString(input), withExtra{})
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	enc, err := NewEncoder(&buf, withExtra{})
+	assert.Nil(t, err)
+	for {
+		var row withExtra
+		if err := d.Read(&row); err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+		assert.Nil(t, enc.Write(row))
+	}
+	assert.Nil(t, enc.Flush())
+	assert.Equal(t, input, buf.String())
+}
+
+func TestMarshal(t *testing.T) {
+	type valid struct {
+		StrField string `csv:"string"`
+		IntField int    `csv:"integer"`
+	}
+
+	data, err := Marshal([]valid{{"foo", 1}, {"bar", 2}})
+	assert.Nil(t, err)
+	assert.Equal(t, "string,integer\nfoo,1\nbar,2\n", string(data))
+
+	data, err = Marshal([]*valid{{"foo", 1}})
+	assert.Nil(t, err)
+	assert.Equal(t, "string,integer\nfoo,1\n", string(data))
+
+	data, err = Marshal([]valid{})
+	assert.Nil(t, err)
+	assert.Equal(t, "string,integer\n", string(data))
+
+	_, err = Marshal([]*valid{nil})
+	assert.Equal(t, errors.New("Marshal source cannot contain nil elements, found one at index 0"), err)
+	_, err = Marshal(valid{})
+	assert.Equal(t, errors.New("Marshal source must be a slice"), err)
+}
+
+func TestMarshalUnmarshalRoundTrip(t *testing.T) {
+	type row struct {
+		Name  string   `csv:"name"`
+		Score *float64 `csv:"score"`
+		Tags  []string `csv:"tags"`
+	}
+	score := 9.5
+	rows := []row{{Name: "a", Score: &score, Tags: []string{"x", "y"}}, {Name: "b", Tags: []string{"z"}}}
+
+	data, err := Marshal(rows)
+	assert.Nil(t, err)
+	var decoded []row
+	assert.Nil(t, Unmarshal(data, &decoded))
+	assert.Equal(t, rows, decoded)
+}
+
+func BenchmarkNewEncoder(b *testing.B) {
+	type row struct {
+		Name    string   `csv:"name"`
+		Count   int      `csv:"count"`
+		Score   *float64 `csv:"score"`
+		Tags    []string `csv:"tags"`
+		Enabled bool     `csv:"enabled"`
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewEncoder(io.Discard, row{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncoderWrite(b *testing.B) {
+	type row struct {
+		Name    string   `csv:"name"`
+		Count   int      `csv:"count"`
+		Score   *float64 `csv:"score"`
+		ID      uint64   `csv:"id"`
+		Enabled bool     `csv:"enabled"`
+	}
+	score := 1.5
+	r := row{Name: "name", Count: 42, Score: &score, ID: 1 << 40, Enabled: true}
+	enc, err := NewEncoder(io.Discard, row{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := enc.Write(r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestEncoderBuffering(t *testing.T) {
+	type valid struct {
+		StrField string `csv:"string"`
+	}
+	var buf bytes.Buffer
+
+	enc, err := NewEncoder(&buf, valid{})
+	assert.Nil(t, err)
+	assert.Nil(t, enc.Write(valid{"foo"}))
+	assert.Equal(t, "", buf.String(), "rows are buffered until flushed")
+	assert.Nil(t, enc.Flush())
+	assert.Equal(t, "string\nfoo\n", buf.String())
+
+	buf.Reset()
+	enc, err = NewEncoder(&buf, valid{}, FlushEachRow())
+	assert.Nil(t, err)
+	assert.Nil(t, enc.Write(valid{"foo"}))
+	assert.Equal(t, "string\nfoo\n", buf.String())
+}
+
+func TestEncoderClose(t *testing.T) {
+	type valid struct {
+		StrField string `csv:"string"`
+	}
+	var buf bytes.Buffer
+
+	enc, err := NewEncoder(&buf, valid{})
+	assert.Nil(t, err)
+	assert.Nil(t, enc.Write(valid{"foo"}))
+	assert.Nil(t, enc.Close())
+	assert.Equal(t, "string\nfoo\n", buf.String())
+	assert.Equal(t, ErrEncoderClosed, enc.Write(valid{"bar"}))
+	assert.Nil(t, enc.Close(), "closing twice is a no-op")
+
+	type withExtra struct {
+		Name  string            `csv:"name"`
+		Extra map[string]string `csv:",extra"`
+	}
+	buf.Reset()
+	extraEnc, err := NewEncoder(&buf, withExtra{})
+	assert.Nil(t, err)
+	assert.Nil(t, extraEnc.Close())
+	assert.Equal(t, "name\n", buf.String(), "headers are written on close if no row was")
+}
+
+func TestEncoderError(t *testing.T) {
+	type valid struct {
+		StrField string `csv:"string"`
+	}
+
+	enc, err := NewEncoder(failingWriter{}, valid{})
+	assert.Nil(t, err)
+	assert.Nil(t, enc.Write(valid{"foo"}))
+	assert.Nil(t, enc.Error())
+	assert.Equal(t, errors.New("disk full"), enc.Flush())
+	assert.Equal(t, errors.New("disk full"), enc.Error())
+
+	enc, err = NewEncoder(failingWriter{}, valid{}, FlushEachRow())
+	assert.Nil(t, err)
+	assert.Equal(t, errors.New("disk full"), enc.Write(valid{"foo"}), "flushing the row fails")
+	assert.Equal(t, errors.New("disk full"), enc.Error())
+}
+
+func TestEncodeNamedTypes(t *testing.T) {
+	type named struct {
+		Tags  namedTags `csv:"tags"`
+		IDs   namedInts `csv:"ids"`
+		Fifty fifty     `csv:"fifty"`
+	}
+	var buf bytes.Buffer
+
+	enc, err := NewEncoder(&buf, named{})
+	assert.Nil(t, err)
+	assert.Nil(t, enc.Write(named{Tags: namedTags{"a", "b"}, IDs: namedInts{1, 2}}))
+	assert.Nil(t, enc.Flush())
+	assert.Equal(t, "tags,ids,fifty\n\"a,b\",\"1,2\",50\n", buf.String())
+}
+
+func TestWriteInvalidSource(t *testing.T) {
+	type valid struct {
+		StrField string `csv:"string"`
+	}
+	type other struct {
+		StrField string `csv:"string"`
+	}
+	var buf bytes.Buffer
+
+	enc, err := NewEncoder(&buf, valid{})
+	assert.Nil(t, err)
+	var nilPtr *valid
+	assert.Equal(t, errors.New("Source struct passed in cannot be a nil pointer"), enc.Write(nilPtr))
+	assert.Equal(t, errors.New("Source struct must be a csvutil.valid, found csvutil.other"), enc.Write(other{}))
+}
+
+func TestEncoderSkipHeader(t *testing.T) {
+	type valid struct {
+		StrField string `csv:"string"`
+		IntField int    `csv:"integer"`
+	}
+	buf := bytes.NewBufferString("string,integer\nfoo,1\n")
+
+	enc, err := NewEncoder(buf, valid{}, SkipHeader())
+	assert.Nil(t, err)
+	assert.Nil(t, enc.Write(valid{"bar", 2}))
+	assert.Nil(t, enc.Close())
+	assert.Equal(t, "string,integer\nfoo,1\nbar,2\n", buf.String())
+
+	type withExtra struct {
+		Name  string            `csv:"name"`
+		Extra map[string]string `csv:",extra"`
+	}
+	buf.Reset()
+	extraEnc, err := NewEncoder(buf, withExtra{}, SkipHeader())
+	assert.Nil(t, err)
+	assert.Nil(t, extraEnc.Write(withExtra{Name: "x", Extra: map[string]string{"notes": "hi"}}))
+	assert.Nil(t, extraEnc.Close())
+	assert.Equal(t, "x,hi\n", buf.String())
+}
+
+func TestEncodeHeaderAliases(t *testing.T) {
+	type aliased struct {
+		Zip string `csv:"zip|zipcode|postal_code"`
+	}
+	data, err := Marshal([]aliased{{"10001"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "zip\n10001\n", string(data), "the first name is used when encoding")
+}
+
+func TestEncodeSliceElementsRoundTrip(t *testing.T) {
+	type S struct {
+		Tags   []string        `csv:"tags"`
+		Paths  []string        `csv:"paths,sep=|"`
+		Scores []float64       `csv:"scores,sep=; "`
+		Waits  []time.Duration `csv:"waits"`
+		Marks  []marshalOnly   `csv:"marks"`
+	}
+	rows := []S{{
+		Tags:   []string{"a,b", "c"},
+		Paths:  []string{`C:\dir|x`, `\`, "y"},
+		Scores: []float64{0.5, 1},
+		Waits:  []time.Duration{90 * time.Second},
+		Marks:  []marshalOnly{{}, {}},
+	}}
+	data, err := Marshal(rows)
+	assert.NoError(t, err)
+	assert.Equal(t, "tags,paths,scores,waits,marks\n"+
+		`"a\,b,c",C:\\dir\|x|\\|y,0.5; 1,1m30s,"m,m"`+"\n", string(data))
+
+	type decoded struct {
+		Tags   []string        `csv:"tags"`
+		Paths  []string        `csv:"paths,sep=|"`
+		Scores []float64       `csv:"scores,sep=; "`
+		Waits  []time.Duration `csv:"waits"`
+	}
+	var out []decoded
+	assert.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, []decoded{{Tags: rows[0].Tags, Paths: rows[0].Paths, Scores: rows[0].Scores, Waits: rows[0].Waits}}, out)
+}
+
+func TestEncodeMultiCharacterSeparatorRoundTrip(t *testing.T) {
+	type S struct {
+		Tags []string `csv:"tags,sep=||"`
+	}
+	rows := []S{
+		{Tags: []string{"a|", "b"}},
+		{Tags: []string{"a", "|b"}},
+		{Tags: []string{"a||b", `\|`}},
+	}
+	data, err := Marshal(rows)
+	assert.NoError(t, err)
+	assert.Equal(t, "tags\n"+`a\|||b`+"\n"+`a||\|b`+"\n"+`a\|\|b||\\\|`+"\n", string(data))
+
+	var out []S
+	assert.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, rows, out)
+}
+
+func TestEncodeNestedStructs(t *testing.T) {
+	type S struct {
+		ID string `csv:"id"`
+		*Audit
+		Home *Address `csv:"home,inline"`
+		Work Address  `csv:"work,inline"`
+	}
+	rows := []S{
+		{ID: "1", Audit: &Audit{CreatedBy: "ada"}, Home: &Address{Street: "1 Main St", City: "Springfield"}},
+		{ID: "2", Work: Address{City: "Shelbyville"}},
+	}
+	data, err := Marshal(rows)
+	assert.NoError(t, err)
+	assert.Equal(t, "id,created_by,updated_by,home.street,home.city,work.street,work.city\n"+
+		"1,ada,,1 Main St,Springfield,,\n"+
+		"2,,,,,,Shelbyville\n", string(data))
+}
+
+func TestEncodeIgnoresDefaultValues(t *testing.T) {
+	// defaults only apply when decoding, so encode-only types may have one
+	type S struct {
+		Mark marshalOnly `csv:"mark,default=m"`
+	}
+	data, err := Marshal([]S{{}})
+	assert.NoError(t, err)
+	assert.Equal(t, "mark\nm\n", string(data))
+}
+
+func TestEncodeSkipsEmbeddedStructsTaggedDash(t *testing.T) {
+	type S struct {
+		ID     string `csv:"id"`
+		*Audit `csv:"-"`
+	}
+	data, err := Marshal([]S{{ID: "1", Audit: &Audit{CreatedBy: "ada"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "id\n1\n", string(data))
+}
+
+func TestEncodeExtraFieldInNilEmbeddedStruct(t *testing.T) {
+	type Extra struct {
+		Other map[string]string `csv:",extra"`
+	}
+	type S struct {
+		ID string `csv:"id"`
+		*Extra
+	}
+	data, err := Marshal([]S{{ID: "1", Extra: &Extra{Other: map[string]string{"x": "y"}}}, {ID: "2"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "id,x\n1,y\n2,\n", string(data))
+}
+
+func TestNewEncoderDestinationTypes(t *testing.T) {
+	type S struct {
+		Name string `csv:"name"`
+	}
+	for _, dest := range []interface{}{S{}, &S{}, []S{}, []*S{}, reflect.TypeOf(S{})} {
+		buf := &bytes.Buffer{}
+		e, err := NewEncoder(buf, dest)
+		if assert.NoError(t, err, "%T", dest) {
+			assert.NoError(t, e.Write(&S{Name: "ada"}), "%T", dest)
+			assert.NoError(t, e.Close(), "%T", dest)
+			assert.Equal(t, "name\nada\n", buf.String(), "%T", dest)
+		}
+	}
+
+	_, err := NewEncoder(&bytes.Buffer{}, 42)
+	assert.Equal(t, fmt.Errorf("expected a struct, pointer to struct, slice of structs or reflect.Type, found int"), err)
+}
+
+var errMarshal = errors.New("cannot marshal")
+
+type failingMarshaler struct{}
+
+func (failingMarshaler) MarshalText() ([]byte, error) {
+	return nil, errMarshal
+}
+
+func TestEncoderWriteErrorChain(t *testing.T) {
+	type S struct {
+		Field failingMarshaler `csv:"field"`
+	}
+	enc, err := NewEncoder(&bytes.Buffer{}, S{})
+	assert.Nil(t, err)
+	err = enc.Write(S{})
+	assert.ErrorIs(t, err, errMarshal, "the marshaler's error can be matched through the Write error")
+}
@@ -0,0 +1,98 @@
+package csvutil
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrMissingColumn is returned when a column tagged as required is not present in the
+	// CSV headers.
+	ErrMissingColumn = errors.New("required column not found")
+	// ErrUnknownHeader is returned when the CSV has a header that does not match any struct
+	// field and the Decoder was created with DisallowUnknownHeaders.
+	ErrUnknownHeader = errors.New("unknown CSV headers")
+	// ErrDuplicateHeader is returned when the same CSV header appears more than once.
+	ErrDuplicateHeader = errors.New("CSV headers must be unique")
+	// ErrColumnCount is returned when a row does not have the same number of columns as
+	// the CSV headers.
+	ErrColumnCount = errors.New("wrong number of columns")
+	// ErrMissingValue is returned when a column tagged as required or notempty has an
+	// empty value.
+	ErrMissingValue = errors.New("required value missing")
+	// ErrEncoderClosed is returned when writing to an Encoder after calling Close.
+	ErrEncoderClosed = errors.New("encoder is closed")
+)
+
+// DecodeError describes a failure to decode a CSV row, pointing at the cell that failed.
+// The underlying cause is available through errors.Is and errors.As.
+type DecodeError struct {
+	// Line is the 1-based line in the input where the failing cell starts.
+	Line int
+	// Column is the 0-based index of the failing cell within the row, or -1 if the error
+	// applies to the whole row.
+	Column int
+	// Header is the header of the failing column as it appears in the CSV, which may be
+	// any of the names the field accepts. It is empty if the error is not tied to a column.
+	Header string
+	// Field is the name of the struct field, the first name in its csv tag.
+	Field string
+	// Value is the raw (whitespace trimmed) value of the failing cell.
+	Value string
+	// Err is the underlying cause.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Column < 0 {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d, column '%s': %s", e.Line, e.Header, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// RowError aggregates every cell of a single row that failed to decode. It is returned by
+// Read when the Decoder was created with the CollectErrors option.
+type RowError struct {
+	// Line is the 1-based line in the input where the row starts.
+	Line int
+	// Errors holds one *DecodeError per failing cell, in column order.
+	Errors []*DecodeError
+}
+
+func (e *RowError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d columns failed to decode: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+func (e *RowError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// ValidationError describes a decoded value that fails a validation option of its csv
+// tag. It is wrapped in a *DecodeError naming the column and value.
+type ValidationError struct {
+	// Rule is the name of the failed option, such as "min" or "email".
+	Rule string
+	// Param is the value of the option as written in the tag, or empty for options
+	// without one.
+	Param string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Param == "" {
+		return fmt.Sprintf("value fails validation rule '%s'", e.Rule)
+	}
+	return fmt.Sprintf("value fails validation rule '%s=%s'", e.Rule, e.Param)
+}
@@ -0,0 +1,160 @@
+package csvutil
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeErrorMessage(t *testing.T) {
+	err := &DecodeError{Line: 3, Column: 1, Header: "integer", Value: "x", Err: ErrMissingValue}
+	assert.EqualError(t, err, "line 3, column 'integer': required value missing")
+
+	err = &DecodeError{Line: 4, Column: -1, Err: ErrColumnCount}
+	assert.EqualError(t, err, "line 4: wrong number of columns")
+}
+
+func TestNewDecoderErrorsIs(t *testing.T) {
+	type S struct {
+		StrField string `csv:"string"`
+		IntField int    `csv:"integer,required"`
+	}
+
+	_, err := NewDecoder(strings.NewReader("string\nx\n"), S{})
+	assert.ErrorIs(t, err, ErrMissingColumn)
+
+	_, err = NewDecoder(strings.NewReader("integer,string,integer\n1,x,2\n"), S{})
+	assert.ErrorIs(t, err, ErrDuplicateHeader)
+}
+
+func TestDecoderReadErrorPosition(t *testing.T) {
+	type S struct {
+		StrField string `csv:"string"`
+		IntField int    `csv:"integer,required"`
+	}
+
+	specs := []struct {
+		msg     string
+		csvFile string
+		err     *DecodeError
+		cause   error
+	}{
+		{
+			msg:     "bad cell after a multi-line cell",
+			csvFile: "string,integer\n\"a\nb\",1\nc,x\n",
+			err:     &DecodeError{Line: 4, Column: 1, Header: "integer", Value: "x"},
+			cause:   strconv.ErrSyntax,
+		},
+		{
+			msg:     "missing required value",
+			csvFile: "integer,string\n1,a\n ,b\n",
+			err:     &DecodeError{Line: 3, Column: 0, Header: "integer", Value: ""},
+			cause:   ErrMissingValue,
+		},
+		{
+			msg:     "column count mismatch",
+			csvFile: "string,integer\na,1\nb\n",
+			err:     &DecodeError{Line: 3, Column: -1},
+			cause:   ErrColumnCount,
+		},
+	}
+
+	for _, s := range specs {
+		t.Run(s.msg, func(t *testing.T) {
+			d, err := NewDecoder(strings.NewReader(s.csvFile), S{})
+			assert.NoError(t, err)
+			var val S
+			for err == nil {
+				err = d.Read(&val)
+			}
+
+			var decodeErr *DecodeError
+			if assert.True(t, errors.As(err, &decodeErr), s.msg) {
+				assert.Equal(t, s.err.Line, decodeErr.Line, s.msg)
+				assert.Equal(t, s.err.Column, decodeErr.Column, s.msg)
+				assert.Equal(t, s.err.Header, decodeErr.Header, s.msg)
+				assert.Equal(t, s.err.Value, decodeErr.Value, s.msg)
+			}
+			assert.ErrorIs(t, err, s.cause, s.msg)
+		})
+	}
+}
+
+func TestDecoderReadCollectErrors(t *testing.T) {
+	type S struct {
+		StrField  string `csv:"string,required"`
+		IntField  int    `csv:"integer"`
+		BoolField bool   `csv:"boolean"`
+		UintField uint8  `csv:"uint"`
+	}
+
+	d, err := NewDecoder(strings.NewReader("string,integer,boolean,uint\n,x,true,300\nok,1,false,2\n"), S{},
+		CollectErrors())
+	assert.NoError(t, err)
+
+	var val S
+	err = d.Read(&val)
+	var rowErr *RowError
+	if assert.True(t, errors.As(err, &rowErr)) {
+		assert.Equal(t, 2, rowErr.Line)
+		if assert.Len(t, rowErr.Errors, 3) {
+			assert.Equal(t, "string", rowErr.Errors[0].Header)
+			assert.Equal(t, "integer", rowErr.Errors[1].Header)
+			assert.Equal(t, "uint", rowErr.Errors[2].Header)
+		}
+	}
+	assert.ErrorIs(t, err, ErrMissingValue)
+	assert.ErrorIs(t, err, strconv.ErrRange)
+	assert.True(t, val.BoolField, "valid columns are still populated")
+
+	assert.NoError(t, d.Read(&val))
+	assert.Equal(t, S{StrField: "ok", IntField: 1, UintField: 2}, val)
+}
+
+func TestRowErrorMessage(t *testing.T) {
+	err := &RowError{
+		Line: 2,
+		Errors: []*DecodeError{
+			{Line: 2, Column: 0, Header: "a", Err: ErrMissingValue},
+			{Line: 2, Column: 2, Header: "c", Err: ErrMissingValue},
+		},
+	}
+	assert.EqualError(t, err, "2 columns failed to decode: line 2, column 'a': required value missing; "+
+		"line 2, column 'c': required value missing")
+}
+
+func TestDecoderReadCollectErrorsEmptyAfterFailure(t *testing.T) {
+	type S struct {
+		IntField int    `csv:"integer"`
+		StrField string `csv:"string"`
+	}
+
+	d, err := NewDecoder(strings.NewReader("integer,string\nx,\n"), S{}, CollectErrors())
+	assert.NoError(t, err)
+	var val S
+	err = d.Read(&val)
+	var rowErr *RowError
+	if assert.ErrorAs(t, err, &rowErr) {
+		assert.Len(t, rowErr.Errors, 1, "empty cells after a failing one are not reported")
+	}
+}
+
+func TestDecodeErrorHeaderFromCSV(t *testing.T) {
+	type S struct {
+		Zip int `csv:"zip|postal_code"`
+	}
+
+	d, err := NewDecoder(strings.NewReader("Postal_Code\nx\n"), S{})
+	assert.NoError(t, err)
+	var val S
+	err = d.Read(&val)
+	var decodeErr *DecodeError
+	if assert.ErrorAs(t, err, &decodeErr) {
+		assert.Equal(t, "Postal_Code", decodeErr.Header, "the header is named as it appears in the CSV")
+		assert.Equal(t, "zip", decodeErr.Field)
+		assert.Contains(t, decodeErr.Error(), "line 2, column 'Postal_Code'")
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 )
 
 var (
@@ -15,9 +18,31 @@ var (
 )
 
 type csvField struct {
-	required   bool
-	fieldName  string
-	fieldIndex int
+	// required is set when the column must be present in the CSV, and notEmpty when its
+	// values must not be empty
+	required  bool
+	notEmpty  bool
+	fieldName string
+	// aliases are other accepted header names for the field, while fieldName is used for
+	// encoding and in errors
+	aliases []string
+	// fieldIndex is the index sequence of the field within the struct, which has several
+	// entries for fields of embedded and inline structs
+	fieldIndex []int
+	// pointer is set for pointers to basic types, which are treated as nullable columns
+	pointer bool
+	// extra is set for the map field that collects columns not mapped to any other field
+	extra bool
+	// layouts and location are set for time.Time fields with a format or tz option, which
+	// replace the type's own text marshaling
+	layouts  []string
+	location *time.Location
+	// separator joins the elements of slice fields, or defaultSeparator if empty
+	separator string
+	// defaultValue is decoded in place of empty values and missing columns, if not empty
+	defaultValue string
+	// rules are the validation options checked on every decoded value
+	rules []validationRule
 	// we cache this to prevent repeated needs for reflection
 	fieldType         reflect.Kind
 	sliceType         reflect.Kind
@@ -25,6 +50,11 @@ type csvField struct {
 	customUnmarshaler bool
 }
 
+// names returns every header name accepted for the field, starting with fieldName.
+func (f csvField) names() []string {
+	return append([]string{f.fieldName}, f.aliases...)
+}
+
 // doesImplement returns true if type `t` implements `ifc` interface
 func doesImplement(t reflect.Type, ifc reflect.Type) bool {
 	if t.Kind() != reflect.Ptr {
@@ -33,40 +63,187 @@ func doesImplement(t reflect.Type, ifc reflect.Type) bool {
 	return t.Implements(ifc)
 }
 
+// isBasicKind returns true for the scalar kinds that can be converted directly to and from text
+func isBasicKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// fieldCache holds the mappings built for each struct type, so that repeatedly creating
+// Decoders and Encoders for a type only walks and validates its fields once. The mappings
+// only depend on the struct type: Decoder and Encoder options are applied to copies of
+// them, so entries never need to be invalidated.
+var fieldCache sync.Map // map[reflect.Type][]csvField
+
+// structTypeOf returns the struct type described by dest, which is either a struct, a
+// pointer to a struct, a slice of structs or of pointers to structs, or a reflect.Type of
+// any of these.
+func structTypeOf(dest interface{}) (reflect.Type, error) {
+	if dest == nil {
+		return nil, fmt.Errorf("provided struct cannot be nil")
+	}
+	t, ok := dest.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(dest)
+	}
+	if t == nil {
+		return nil, fmt.Errorf("provided struct cannot be nil")
+	}
+	structType := t
+	if structType.Kind() == reflect.Slice {
+		structType = structType.Elem()
+	}
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected a struct, pointer to struct, slice of structs or reflect.Type, found %s", t)
+	}
+	return structType, nil
+}
+
 // structureFromStruct builds an internal mapping of how to translate a struct to and from
 // a CSV line. This vets that the struct actually has fields tagged for CSV marshaling
-// and ensures that we are able to marshal _or_ unmarshal each field from text.
+// and ensures that we are able to marshal _or_ unmarshal each field from text. dest is
+// anything accepted by structTypeOf.
+// Mappings are cached per type, and callers get their own copy of the cached slice.
 func structureFromStruct(dest interface{}) ([]csvField, error) {
-	if dest == nil {
-		return nil, fmt.Errorf("provided struct cannot be nil")
+	structType, err := structTypeOf(dest)
+	if err != nil {
+		return nil, err
 	}
+	if cached, ok := fieldCache.Load(structType); ok {
+		return slices.Clone(cached.([]csvField)), nil
+	}
+	csvMappings, err := typeFields(structType)
+	if err != nil {
+		return nil, err
+	}
+	cached, _ := fieldCache.LoadOrStore(structType, csvMappings)
+	return slices.Clone(cached.([]csvField)), nil
+}
+
+// typeFields walks the fields of structType to build its mappings.
+func typeFields(structType reflect.Type) ([]csvField, error) {
+	fields, err := collectFields(structType, nil, []reflect.Type{structType}, []csvField{})
+	if err != nil {
+		return nil, err
+	}
+	csvMappings, err := dominantFields(fields)
+	if err != nil {
+		return nil, err
+	}
+	if len(csvMappings) == 0 {
+		return nil, fmt.Errorf("no fields found for CSV marshaling")
+	}
+
+	return csvMappings, nil
+}
 
-	csvMappings := []csvField{}
-	structType := reflect.TypeOf(dest)
-	for i := 0; i < reflect.ValueOf(dest).NumField(); i++ {
+// collectFields appends the mappings for the fields of structType, which is found at index
+// within the outermost struct, to csvMappings. Fields of embedded structs are flattened
+// into their parent, and fields of inline structs are prefixed with the inline field's
+// name. Fields tagged "-" are skipped, embedded ones included, while a "-" followed by
+// options names a column "-", as in encoding/json. path holds the struct types being
+// walked, to stop at recursive types.
+func collectFields(structType reflect.Type, index []int, path []reflect.Type, csvMappings []csvField) ([]csvField, error) {
+	for i := 0; i < structType.NumField(); i++ {
 		fieldInfo := structType.Field(i)
-		tags := strings.Split(fieldInfo.Tag.Get("csv"), ",")
-		if len(tags) > 2 {
-			return nil, fmt.Errorf("csvutil tags should only include name [and optionally required], found %d values", len(tags))
+		fieldIndex := append(slices.Clip(index), i)
+		tag := fieldInfo.Tag.Get("csv")
+		if tag == "-" {
+			continue
 		}
+		tags := strings.Split(tag, ",")
 		csvFieldName := tags[0]
-		if csvFieldName == "" { // for now, ignore fields w/o a name
+		opts, err := parseTagOptions(tags[1:])
+		if err != nil {
+			return nil, err
+		}
+		nestedType := fieldInfo.Type
+		if nestedType.Kind() == reflect.Ptr {
+			nestedType = nestedType.Elem()
+		}
+		// embedded structs without a csv name are flattened, like encoding/json does
+		embedded := fieldInfo.Anonymous && csvFieldName == "" && !opts.extra && nestedType.Kind() == reflect.Struct
+		if csvFieldName == "" && !opts.extra && !opts.inline && !embedded { // for now, ignore fields w/o a name
 			continue
 		}
-		// if a field does have a csv tag, we must be able to access it
-		if unexportedfield.MatchString(fieldInfo.Name) {
+
+		if embedded {
+			// fields promoted from an unexported embedded struct are still accessible, unless
+			// it is a pointer that would need to be allocated when decoding
+			if !fieldInfo.IsExported() && fieldInfo.Type.Kind() == reflect.Ptr {
+				continue
+			}
+			if slices.Contains(path, nestedType) {
+				continue
+			}
+		} else if unexportedfield.MatchString(fieldInfo.Name) {
+			// if a field does have a csv tag, we must be able to access it
 			return nil, fmt.Errorf("cannot access field '%s'", fieldInfo.Name)
 		}
 
-		if len(tags) == 2 && tags[1] != "required" {
-			return nil, fmt.Errorf("unknown second value found in csv tags: '%s'", tags[1])
+		if opts.extra {
+			if opts.hasColumnOptions() || opts.inline {
+				return nil, fmt.Errorf("extra field '%s' cannot have other csv tag options", fieldInfo.Name)
+			}
+			field, err := extraFieldFromStruct(fieldInfo, csvFieldName, fieldIndex, csvMappings)
+			if err != nil {
+				return nil, err
+			}
+			csvMappings = append(csvMappings, field)
+			continue
+		}
+
+		var names []string
+		if csvFieldName != "" {
+			names = strings.Split(csvFieldName, "|")
+			if slices.Contains(names, "") {
+				return nil, fmt.Errorf("empty header alias found in csv tags: '%s'", csvFieldName)
+			}
+		}
+
+		if embedded || opts.inline {
+			if nestedType.Kind() != reflect.Struct {
+				return nil, fmt.Errorf("inline option is only supported on struct fields, found %s for field '%s'",
+					fieldInfo.Type, fieldInfo.Name)
+			}
+			if opts.hasColumnOptions() {
+				return nil, fmt.Errorf("inline field '%s' cannot have other csv tag options", fieldInfo.Name)
+			}
+			if slices.Contains(path, nestedType) {
+				return nil, fmt.Errorf("inline field '%s' cannot contain its own type %s", fieldInfo.Name, nestedType)
+			}
+			start := len(csvMappings)
+			csvMappings, err = collectFields(nestedType, fieldIndex, append(slices.Clip(path), nestedType), csvMappings)
+			if err != nil {
+				return nil, err
+			}
+			if names != nil {
+				for j := start; j < len(csvMappings); j++ {
+					if !csvMappings[j].extra {
+						csvMappings[j] = prefixField(csvMappings[j], names)
+					}
+				}
+			}
+			continue
 		}
-		requiredField := len(tags) == 2
 
 		field := csvField{
-			required:   requiredField,
-			fieldName:  csvFieldName,
-			fieldIndex: i,
+			required:   opts.required,
+			notEmpty:   opts.notEmpty || (opts.required && !opts.allowEmpty && opts.defaultValue == ""),
+			fieldName:  names[0],
+			fieldIndex: fieldIndex,
+		}
+		if len(names) > 1 {
+			field.aliases = names[1:]
 		}
 
 		if doesImplement(fieldInfo.Type, textMarshalerType) {
@@ -76,25 +253,70 @@ func structureFromStruct(dest interface{}) ([]csvField, error) {
 			field.customUnmarshaler = true
 		}
 
+		if opts.layouts != nil || opts.location != nil {
+			if t := fieldInfo.Type; t != timeType && !((t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice) && t.Elem() == timeType) {
+				return nil, fmt.Errorf("format and tz options are only supported on time.Time fields and slices, found %s for field '%s'",
+					fieldInfo.Type, fieldInfo.Name)
+			}
+			field.layouts = opts.layouts
+			if field.layouts == nil {
+				field.layouts = []string{time.RFC3339Nano}
+			}
+			field.location = opts.location
+			field.customMarshaler = false
+			field.customUnmarshaler = false
+		}
+
+		if opts.separator != "" {
+			if fieldInfo.Type.Kind() != reflect.Slice {
+				return nil, fmt.Errorf("sep option is only supported on slice fields, found %s for field '%s'",
+					fieldInfo.Type, fieldInfo.Name)
+			}
+			field.separator = opts.separator
+		}
+
 		fieldType := fieldInfo.Type
+		if fieldType.Kind() == reflect.Ptr && (isBasicKind(fieldType.Elem().Kind()) || field.layouts != nil) {
+			field.pointer = true
+			fieldType = fieldType.Elem()
+		}
 		switch fieldType.Kind() {
 		case reflect.Invalid:
 			return nil, fmt.Errorf("got invalid type: %#v", fieldInfo)
 		case reflect.String:
 			field.fieldType = reflect.String
-		case reflect.Int:
-			field.fieldType = reflect.Int
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			field.fieldType = fieldType.Kind()
 		case reflect.Bool:
 			field.fieldType = reflect.Bool
 		case reflect.Slice:
 			field.fieldType = reflect.Slice
-			switch fieldInfo.Type.Elem().Kind() {
-			case reflect.String:
-				field.sliceType = reflect.String
-			case reflect.Int:
-				field.sliceType = reflect.Int
+			elemType := fieldType.Elem()
+			switch {
+			case field.layouts != nil:
+				field.sliceType = reflect.Struct
+			case isBasicKind(elemType.Kind()):
+				field.sliceType = elemType.Kind()
+			case field.customMarshaler || field.customUnmarshaler:
+				// the slice type converts itself, so its elements are never converted
+				field.sliceType = reflect.Invalid
+			case elemType.Kind() != reflect.Ptr &&
+				(doesImplement(elemType, textMarshalerType) || doesImplement(elemType, textUnmarshalerType)):
+				// whether elements implement the marshaler needed for each direction is
+				// audited by the NewEncoder/NewDecoder functions
+				field.sliceType = reflect.Invalid
 			default:
-				return nil, fmt.Errorf("only string & int slices allowed")
+				return nil, fmt.Errorf("unsupported slice element type %s for field '%s'", elemType, fieldInfo.Name)
+			}
+		case reflect.Struct:
+			// time.Time fields with layouts are converted directly, other structs need to
+			// implement the marshaler interfaces
+			if field.layouts != nil {
+				field.fieldType = reflect.Struct
+			} else {
+				field.fieldType = reflect.Invalid
 			}
 		default:
 			// NOTE: whether or not a marshaler type is implemented for all unknown types will
@@ -102,17 +324,252 @@ func structureFromStruct(dest interface{}) ([]csvField, error) {
 			field.fieldType = reflect.Invalid
 		}
 
-		for _, m := range csvMappings {
-			if m.fieldName == field.fieldName {
-				return nil, fmt.Errorf("two attributes w/ csv field name: '%s'", field.fieldName)
+		valueType := fieldInfo.Type
+		if valueType.Kind() == reflect.Ptr {
+			valueType = valueType.Elem()
+		}
+		for _, r := range opts.rules {
+			rule, err := compileRule(r, valueType)
+			if err != nil {
+				return nil, fmt.Errorf("field '%s': %w", fieldInfo.Name, err)
 			}
+			field.rules = append(field.rules, rule)
 		}
 
+		// defaults are checked by NewDecoder, as encode-only types cannot decode them
+		field.defaultValue = opts.defaultValue
+
 		csvMappings = append(csvMappings, field)
 	}
-	if len(csvMappings) == 0 {
-		return nil, fmt.Errorf("no fields found for CSV marshaling")
+	return csvMappings, nil
+}
+
+// prefixField names a field of an inline struct after the inline field, so that "street"
+// within `csv:"addr,inline"` becomes "addr.street". Every alias of the inline field is
+// combined with every alias of the field.
+func prefixField(f csvField, prefixes []string) csvField {
+	names := make([]string, 0, len(prefixes)*(len(f.aliases)+1))
+	for _, prefix := range prefixes {
+		for _, name := range f.names() {
+			names = append(names, prefix+"."+name)
+		}
 	}
+	f.fieldName = names[0]
+	f.aliases = nil
+	if len(names) > 1 {
+		f.aliases = names[1:]
+	}
+	return f
+}
 
-	return csvMappings, nil
+// dominantFields drops every field hidden by a field of the same name in a shallower
+// struct, the way encoding/json resolves fields promoted from embedded structs. Fields
+// with the same name at the same depth are an error.
+func dominantFields(fields []csvField) ([]csvField, error) {
+	minDepth := make(map[string]int, len(fields))
+	for _, f := range fields {
+		if f.extra {
+			continue
+		}
+		for _, name := range f.names() {
+			if depth, ok := minDepth[name]; !ok || len(f.fieldIndex) < depth {
+				minDepth[name] = len(f.fieldIndex)
+			}
+		}
+	}
+
+	seen := make(map[string]bool, len(minDepth))
+	dominant := make([]csvField, 0, len(fields))
+	for _, f := range fields {
+		if f.extra {
+			dominant = append(dominant, f)
+			continue
+		}
+		names := f.names()
+		if slices.ContainsFunc(names, func(name string) bool { return minDepth[name] < len(f.fieldIndex) }) {
+			continue
+		}
+		for _, name := range names {
+			if seen[name] {
+				return nil, fmt.Errorf("two attributes w/ csv field name: '%s'", name)
+			}
+			seen[name] = true
+		}
+		dominant = append(dominant, f)
+	}
+	return dominant, nil
+}
+
+// defaultSeparator joins the elements of slice fields without a sep option.
+const defaultSeparator = ","
+
+// sliceSeparator returns the string joining the elements of a slice field.
+func (f csvField) sliceSeparator() string {
+	if f.separator == "" {
+		return defaultSeparator
+	}
+	return f.separator
+}
+
+// elemField returns the mapping used to convert each element of a slice field, whose
+// elements are of type t.
+func (f csvField) elemField(t reflect.Type) csvField {
+	elem := csvField{
+		fieldName: f.fieldName,
+		fieldType: f.sliceType,
+		layouts:   f.layouts,
+		location:  f.location,
+	}
+	if f.layouts == nil {
+		elem.customMarshaler = doesImplement(t, textMarshalerType)
+		elem.customUnmarshaler = doesImplement(t, textUnmarshalerType)
+	}
+	return elem
+}
+
+// tagOptions holds the options that follow the name in a csv tag.
+type tagOptions struct {
+	required   bool
+	notEmpty   bool
+	allowEmpty bool
+	extra      bool
+	layouts    []string
+	location   *time.Location
+	separator  string
+	inline     bool
+	// defaultValue is set by "default=value", and is never empty when set
+	defaultValue string
+	// rules hold the validation options, whose parameters are parsed once the field's
+	// type is known
+	rules []validationRule
+}
+
+// hasColumnOptions reports whether any of the options that apply to a single column are
+// set, which the extra and inline options cannot be combined with.
+func (o tagOptions) hasColumnOptions() bool {
+	return o.required || o.notEmpty || o.allowEmpty || o.layouts != nil || o.location != nil ||
+		o.separator != "" || o.defaultValue != "" || o.rules != nil
+}
+
+// parseTagOptions parses the comma separated options of a csv tag. Options are either
+// flags, like "required", or key=value pairs, like "format=2006-01-02".
+//
+// The column of a "required" field must be present in the CSV, and for compatibility its
+// values must not be empty either unless the field is also tagged "allowempty". Values of
+// a "notempty" field must not be empty, but its column may be missing. A "default=value"
+// is decoded in place of empty values and missing columns, so a required field with a
+// default only needs its column to be present. A default value cannot contain commas, so
+// the default of a slice with several elements needs a "sep=" other than ",".
+//
+// The validation options "min=", "max=", "len=", "oneof=" (space separated values),
+// "regex=" and "email" are checked against every non-empty value decoded by Read. A regex
+// takes the rest of the tag, commas included, so it must be the last option. A format may
+// also contain commas followed by a space, as in "format=Jan 2, 2006": the parts that
+// start with a space are joined back into the layout.
+func parseTagOptions(options []string) (tagOptions, error) {
+	var opts tagOptions
+	for i := 0; i < len(options); i++ {
+		option := options[i]
+		key, value, hasValue := strings.Cut(option, "=")
+		if key == "regex" && hasValue {
+			// patterns may contain commas, so regex must be the last option and takes the
+			// rest of the tag
+			pattern := strings.Join(append([]string{value}, options[i+1:]...), ",")
+			opts.rules = append(opts.rules, validationRule{name: key, param: pattern})
+			break
+		}
+		switch {
+		case option == "required":
+			opts.required = true
+		case option == "notempty":
+			opts.notEmpty = true
+		case option == "allowempty":
+			opts.allowEmpty = true
+		case option == "extra":
+			opts.extra = true
+		case option == "inline":
+			opts.inline = true
+		case key == "format" && hasValue:
+			for i+1 < len(options) && strings.HasPrefix(options[i+1], " ") {
+				i++
+				value += "," + options[i]
+			}
+			// the first layout is used for encoding, and all of them are tried in turn
+			// when decoding
+			opts.layouts = strings.Split(value, "|")
+			if slices.Contains(opts.layouts, "") {
+				return tagOptions{}, fmt.Errorf("empty time format found in csv tags: 'format=%s'", value)
+			}
+		case key == "tz" && hasValue:
+			loc, err := time.LoadLocation(value)
+			if err != nil {
+				return tagOptions{}, fmt.Errorf("invalid time zone found in csv tags: '%s': %w", option, err)
+			}
+			opts.location = loc
+		case key == "sep" && hasValue:
+			// backslashes escape separators within elements, so they cannot be separators
+			if value == "" || strings.Contains(value, `\`) {
+				return tagOptions{}, fmt.Errorf("invalid slice separator found in csv tags: '%s'", option)
+			}
+			opts.separator = value
+		case (key == "min" || key == "max" || key == "len" || key == "oneof") && hasValue:
+			if value == "" {
+				return tagOptions{}, fmt.Errorf("empty validation option found in csv tags: '%s'", option)
+			}
+			opts.rules = append(opts.rules, validationRule{name: key, param: value})
+		case option == "email":
+			opts.rules = append(opts.rules, validationRule{name: option})
+		case key == "default" && hasValue:
+			if value == "" {
+				return tagOptions{}, fmt.Errorf("empty default value found in csv tags: '%s'", option)
+			}
+			opts.defaultValue = value
+		default:
+			return tagOptions{}, fmt.Errorf("unknown option found in csv tags: '%s'", option)
+		}
+	}
+	if opts.notEmpty && opts.allowEmpty {
+		return tagOptions{}, fmt.Errorf("csv tags cannot include both notempty and allowempty")
+	}
+	if opts.notEmpty && opts.defaultValue != "" {
+		return tagOptions{}, fmt.Errorf("csv tags cannot include both notempty and a default value")
+	}
+	return opts, nil
+}
+
+// extraFieldFromStruct vets a field tagged with the "extra" option, which must be an unnamed
+// map[string]string and the only such field in the struct.
+func extraFieldFromStruct(fieldInfo reflect.StructField, name string, index []int, csvMappings []csvField) (csvField, error) {
+	if name != "" {
+		return csvField{}, fmt.Errorf("extra field '%s' cannot have a csv field name: '%s'", fieldInfo.Name, name)
+	}
+	t := fieldInfo.Type
+	if t.Kind() != reflect.Map || t.Key().Kind() != reflect.String || t.Elem().Kind() != reflect.String {
+		return csvField{}, fmt.Errorf("extra field '%s' must be a map[string]string", fieldInfo.Name)
+	}
+	for _, m := range csvMappings {
+		if m.extra {
+			return csvField{}, fmt.Errorf("only one extra field allowed, found '%s'", fieldInfo.Name)
+		}
+	}
+	return csvField{
+		fieldIndex: index,
+		fieldType:  reflect.Map,
+		extra:      true,
+	}, nil
+}
+
+// splitExtraField separates the field tagged with the "extra" option from the fields that
+// map to named columns. The returned field is nil if the struct has no extra field.
+func splitExtraField(mappings []csvField) ([]csvField, *csvField) {
+	var extra *csvField
+	named := make([]csvField, 0, len(mappings))
+	for _, m := range mappings {
+		if m.extra {
+			extra = &m
+			continue
+		}
+		named = append(named, m)
+	}
+	return named, extra
 }
@@ -3,6 +3,7 @@ package csvutil
 import (
 	"fmt"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -58,7 +59,7 @@ func TestStructureFromStruct(t *testing.T) {
 			mapping: []csvField{
 				csvField{
 					fieldName:  "integer",
-					fieldIndex: 0,
+					fieldIndex: []int{0},
 					fieldType:  reflect.Int,
 				},
 			},
@@ -71,8 +72,9 @@ func TestStructureFromStruct(t *testing.T) {
 			mapping: []csvField{
 				csvField{
 					required:   true,
+					notEmpty:   true,
 					fieldName:  "integer",
-					fieldIndex: 0,
+					fieldIndex: []int{0},
 					fieldType:  reflect.Int,
 				},
 			},
@@ -87,12 +89,12 @@ func TestStructureFromStruct(t *testing.T) {
 			mapping: []csvField{
 				csvField{
 					fieldName:  "f1",
-					fieldIndex: 0,
+					fieldIndex: []int{0},
 					fieldType:  reflect.Int,
 				},
 				csvField{
 					fieldName:  "f2",
-					fieldIndex: 2,
+					fieldIndex: []int{2},
 					fieldType:  reflect.String,
 				},
 			},
@@ -105,7 +107,7 @@ func TestStructureFromStruct(t *testing.T) {
 			mapping: []csvField{
 				csvField{
 					fieldName:  "f1",
-					fieldIndex: 0,
+					fieldIndex: []int{0},
 					fieldType:  reflect.Slice,
 					sliceType:  reflect.String,
 				},
@@ -120,12 +122,181 @@ func TestStructureFromStruct(t *testing.T) {
 			mapping: []csvField{
 				csvField{
 					fieldName:  "f1",
-					fieldIndex: 0,
+					fieldIndex: []int{0},
 					fieldType:  reflect.Slice,
 					sliceType:  reflect.Int,
 				},
 			},
 		},
+		{
+			msg: "struct w/ numeric fields",
+			s: struct {
+				Field1 int64   `csv:"f1"`
+				Field2 uint8   `csv:"f2"`
+				Field3 float64 `csv:"f3"`
+			}{},
+			mapping: []csvField{
+				csvField{
+					fieldName:  "f1",
+					fieldIndex: []int{0},
+					fieldType:  reflect.Int64,
+				},
+				csvField{
+					fieldName:  "f2",
+					fieldIndex: []int{1},
+					fieldType:  reflect.Uint8,
+				},
+				csvField{
+					fieldName:  "f3",
+					fieldIndex: []int{2},
+					fieldType:  reflect.Float64,
+				},
+			},
+		},
+		{
+			msg: "struct w/ extra field",
+			s: struct {
+				Field1 string            `csv:"f1"`
+				Extra  map[string]string `csv:",extra"`
+			}{},
+			mapping: []csvField{
+				csvField{
+					fieldName:  "f1",
+					fieldIndex: []int{0},
+					fieldType:  reflect.String,
+				},
+				csvField{
+					fieldIndex: []int{1},
+					fieldType:  reflect.Map,
+					extra:      true,
+				},
+			},
+		},
+		{
+			msg: "struct w/ named extra field",
+			s: struct {
+				Extra map[string]string `csv:"f1,extra"`
+			}{},
+			err: fmt.Errorf("extra field 'Extra' cannot have a csv field name: 'f1'"),
+		},
+		{
+			msg: "struct w/ extra field of wrong type",
+			s: struct {
+				Extra map[string]int `csv:",extra"`
+			}{},
+			err: fmt.Errorf("extra field 'Extra' must be a map[string]string"),
+		},
+		{
+			msg: "struct w/ two extra fields",
+			s: struct {
+				Extra1 map[string]string `csv:",extra"`
+				Extra2 map[string]string `csv:",extra"`
+			}{},
+			err: fmt.Errorf("only one extra field allowed, found 'Extra2'"),
+		},
+		{
+			msg: "struct w/ header aliases",
+			s: struct {
+				Zip string `csv:"zip|zipcode|postal_code,required"`
+			}{},
+			mapping: []csvField{
+				csvField{
+					required:   true,
+					notEmpty:   true,
+					fieldName:  "zip",
+					aliases:    []string{"zipcode", "postal_code"},
+					fieldIndex: []int{0},
+					fieldType:  reflect.String,
+				},
+			},
+		},
+		{
+			msg: "struct w/ empty header alias",
+			s: struct {
+				Zip string `csv:"zip||postal_code"`
+			}{},
+			err: fmt.Errorf("empty header alias found in csv tags: 'zip||postal_code'"),
+		},
+		{
+			msg: "struct w/ alias repeating another field name",
+			s: struct {
+				Zip  string `csv:"zip"`
+				Code string `csv:"code|zip"`
+			}{},
+			err: fmt.Errorf("two attributes w/ csv field name: 'zip'"),
+		},
+		{
+			msg: "struct w/ required, notempty and allowempty options",
+			s: struct {
+				Both     string `csv:"both,required"`
+				Column   string `csv:"column,required,allowempty"`
+				Value    string `csv:"value,notempty"`
+				Optional string `csv:"optional"`
+			}{},
+			mapping: []csvField{
+				csvField{required: true, notEmpty: true, fieldName: "both", fieldIndex: []int{0}, fieldType: reflect.String},
+				csvField{required: true, fieldName: "column", fieldIndex: []int{1}, fieldType: reflect.String},
+				csvField{notEmpty: true, fieldName: "value", fieldIndex: []int{2}, fieldType: reflect.String},
+				csvField{fieldName: "optional", fieldIndex: []int{3}, fieldType: reflect.String},
+			},
+		},
+		{
+			msg: "struct w/ notempty and allowempty options",
+			s: struct {
+				Field1 string `csv:"f1,notempty,allowempty"`
+			}{},
+			err: fmt.Errorf("csv tags cannot include both notempty and allowempty"),
+		},
+		{
+			msg: "struct w/ default values",
+			s: struct {
+				Country string   `csv:"country,default=US"`
+				Count   *int     `csv:"count,required,default=1"`
+				Tags    []string `csv:"tags,default=a|b,sep=|"`
+			}{},
+			mapping: []csvField{
+				csvField{fieldName: "country", fieldIndex: []int{0}, fieldType: reflect.String, defaultValue: "US"},
+				csvField{required: true, fieldName: "count", fieldIndex: []int{1}, pointer: true, fieldType: reflect.Int, defaultValue: "1"},
+				csvField{
+					fieldName:    "tags",
+					fieldIndex:   []int{2},
+					separator:    "|",
+					defaultValue: "a|b",
+					fieldType:    reflect.Slice,
+					sliceType:    reflect.String,
+				},
+			},
+		},
+		{
+			msg: "struct w/ default value for a type that cannot be decoded",
+			s: struct {
+				Field1 marshalOnly `csv:"f1,default=m"`
+			}{},
+			mapping: []csvField{
+				csvField{fieldName: "f1", fieldIndex: []int{0}, customMarshaler: true, defaultValue: "m"},
+			},
+		},
+		{
+			msg: "struct w/ default value containing a comma",
+			s: struct {
+				Tags []string `csv:"tags,default=a,b"`
+			}{},
+			err: fmt.Errorf("unknown option found in csv tags: 'b'"),
+		},
+		{
+			msg: "struct w/ empty default value",
+			s: struct {
+				Field1 string `csv:"f1,default="`
+			}{},
+			err: fmt.Errorf("empty default value found in csv tags: 'default='"),
+		},
+		{
+			msg: "struct w/ notempty and default value",
+			s: struct {
+				Field1 string `csv:"f1,notempty,default=x"`
+			}{},
+			err: fmt.Errorf("csv tags cannot include both notempty and a default value"),
+		},
 		{
 			msg: "struct w/ no fields",
 			s:   struct{}{},
@@ -150,7 +321,14 @@ func TestStructureFromStruct(t *testing.T) {
 			s: struct {
 				Field1 int `csv:"f1,plox-require-field"`
 			}{},
-			err: fmt.Errorf("unknown second value found in csv tags: 'plox-require-field'"),
+			err: fmt.Errorf("unknown option found in csv tags: 'plox-require-field'"),
+		},
+		{
+			msg: "struct w/ misspelled csv tag after a format",
+			s: struct {
+				At time.Time `csv:"at,format=2006-01-02,requried"`
+			}{},
+			err: fmt.Errorf("unknown option found in csv tags: 'requried'"),
 		},
 		{
 			msg: "struct w/ repeat csv fields (f1)",
@@ -178,7 +356,7 @@ func TestStructureFromStruct(t *testing.T) {
 			mapping: []csvField{
 				csvField{
 					fieldName:  "f1",
-					fieldIndex: 0,
+					fieldIndex: []int{0},
 					fieldType:  reflect.String,
 				},
 			},
@@ -191,13 +369,190 @@ func TestStructureFromStruct(t *testing.T) {
 			mapping: []csvField{
 				csvField{
 					fieldName:         "f1",
-					fieldIndex:        0,
+					fieldIndex:        []int{0},
 					fieldType:         reflect.Invalid,
 					customMarshaler:   true,
 					customUnmarshaler: true,
 				},
 			},
 		},
+		{
+			msg: "struct w/ time.Time formats and time zone",
+			s: struct {
+				Day   time.Time  `csv:"day,required,format=2006-01-02|01/02/2006"`
+				At    *time.Time `csv:"at,tz=UTC"`
+				Stamp time.Time  `csv:"stamp,format=unix"`
+			}{},
+			mapping: []csvField{
+				csvField{
+					required:   true,
+					notEmpty:   true,
+					fieldName:  "day",
+					fieldIndex: []int{0},
+					fieldType:  reflect.Struct,
+					layouts:    []string{"2006-01-02", "01/02/2006"},
+				},
+				csvField{
+					fieldName:  "at",
+					fieldIndex: []int{1},
+					pointer:    true,
+					fieldType:  reflect.Struct,
+					layouts:    []string{time.RFC3339Nano},
+					location:   time.UTC,
+				},
+				csvField{
+					fieldName:  "stamp",
+					fieldIndex: []int{2},
+					fieldType:  reflect.Struct,
+					layouts:    []string{"unix"},
+				},
+			},
+		},
+		{
+			msg: "struct w/ time.Time formats containing commas",
+			s: struct {
+				Day time.Time `csv:"day,format=Jan 2, 2006|2006-01-02,tz=UTC,required"`
+				At  time.Time `csv:"at,format=Mon, 02 Jan 2006 15:04:05 MST"`
+			}{},
+			mapping: []csvField{
+				csvField{
+					required:   true,
+					notEmpty:   true,
+					fieldName:  "day",
+					fieldIndex: []int{0},
+					fieldType:  reflect.Struct,
+					layouts:    []string{"Jan 2, 2006", "2006-01-02"},
+					location:   time.UTC,
+				},
+				csvField{
+					fieldName:  "at",
+					fieldIndex: []int{1},
+					fieldType:  reflect.Struct,
+					layouts:    []string{time.RFC1123},
+				},
+			},
+		},
+		{
+			msg: "struct w/ time.Duration",
+			s: struct {
+				Field1 time.Duration `csv:"f1"`
+			}{},
+			mapping: []csvField{
+				csvField{
+					fieldName:  "f1",
+					fieldIndex: []int{0},
+					fieldType:  reflect.Int64,
+				},
+			},
+		},
+		{
+			msg: "struct w/ format on a non-time field",
+			s: struct {
+				Field1 time.Duration `csv:"f1,format=unix"`
+			}{},
+			err: fmt.Errorf("format and tz options are only supported on time.Time fields and slices, found time.Duration for field 'Field1'"),
+		},
+		{
+			msg: "struct w/ empty time format",
+			s: struct {
+				Field1 time.Time `csv:"f1,format=2006||unix"`
+			}{},
+			err: fmt.Errorf("empty time format found in csv tags: 'format=2006||unix'"),
+		},
+		{
+			msg: "struct w/ options on the extra field",
+			s: struct {
+				Extra map[string]string `csv:",extra,required"`
+			}{},
+			err: fmt.Errorf("extra field 'Extra' cannot have other csv tag options"),
+		},
+		{
+			msg: "struct w/ slice separator and element types",
+			s: struct {
+				Tags   []string        `csv:"tags,sep=|"`
+				Scores []float64       `csv:"scores,sep=; "`
+				Ranks  []fifty         `csv:"ranks"`
+				Days   []time.Time     `csv:"days,format=2006-01-02"`
+				Waits  []time.Duration `csv:"waits"`
+			}{},
+			mapping: []csvField{
+				csvField{
+					fieldName:  "tags",
+					fieldIndex: []int{0},
+					separator:  "|",
+					fieldType:  reflect.Slice,
+					sliceType:  reflect.String,
+				},
+				csvField{
+					fieldName:  "scores",
+					fieldIndex: []int{1},
+					separator:  "; ",
+					fieldType:  reflect.Slice,
+					sliceType:  reflect.Float64,
+				},
+				csvField{
+					fieldName:  "ranks",
+					fieldIndex: []int{2},
+					fieldType:  reflect.Slice,
+					sliceType:  reflect.String,
+				},
+				csvField{
+					fieldName:  "days",
+					fieldIndex: []int{3},
+					layouts:    []string{"2006-01-02"},
+					fieldType:  reflect.Slice,
+					sliceType:  reflect.Struct,
+				},
+				csvField{
+					fieldName:  "waits",
+					fieldIndex: []int{4},
+					fieldType:  reflect.Slice,
+					sliceType:  reflect.Int64,
+				},
+			},
+		},
+		{
+			msg: "struct w/ slice of marshalers",
+			s: struct {
+				Times []time.Time `csv:"times"`
+			}{},
+			mapping: []csvField{
+				csvField{
+					fieldName:  "times",
+					fieldIndex: []int{0},
+					fieldType:  reflect.Slice,
+					sliceType:  reflect.Invalid,
+				},
+			},
+		},
+		{
+			msg: "struct w/ unsupported slice element type",
+			s: struct {
+				Field1 []*int `csv:"f1"`
+			}{},
+			err: fmt.Errorf("unsupported slice element type *int for field 'Field1'"),
+		},
+		{
+			msg: "struct w/ separator containing a backslash",
+			s: struct {
+				Field1 []string `csv:"f1,sep=\\"`
+			}{},
+			err: fmt.Errorf("invalid slice separator found in csv tags: 'sep=\\'"),
+		},
+		{
+			msg: "struct w/ empty separator",
+			s: struct {
+				Field1 []string `csv:"f1,sep="`
+			}{},
+			err: fmt.Errorf("invalid slice separator found in csv tags: 'sep='"),
+		},
+		{
+			msg: "struct w/ separator on a non-slice field",
+			s: struct {
+				Field1 string `csv:"f1,sep=|"`
+			}{},
+			err: fmt.Errorf("sep option is only supported on slice fields, found string for field 'Field1'"),
+		},
 		{
 			msg: "struct w/ string pointer",
 			s: struct {
@@ -205,11 +560,10 @@ func TestStructureFromStruct(t *testing.T) {
 			}{},
 			mapping: []csvField{
 				csvField{
-					fieldName:         "f1",
-					fieldIndex:        0,
-					fieldType:         reflect.Invalid,
-					customMarshaler:   false,
-					customUnmarshaler: false,
+					fieldName:  "f1",
+					fieldIndex: []int{0},
+					pointer:    true,
+					fieldType:  reflect.String,
 				},
 			},
 		},
@@ -221,7 +575,7 @@ func TestStructureFromStruct(t *testing.T) {
 			mapping: []csvField{
 				csvField{
 					fieldName:         "f1",
-					fieldIndex:        0,
+					fieldIndex:        []int{0},
 					fieldType:         reflect.String,
 					customMarshaler:   true,
 					customUnmarshaler: true,
@@ -236,8 +590,9 @@ func TestStructureFromStruct(t *testing.T) {
 			mapping: []csvField{
 				csvField{
 					fieldName:         "f1",
-					fieldIndex:        0,
-					fieldType:         reflect.Invalid,
+					fieldIndex:        []int{0},
+					pointer:           true,
+					fieldType:         reflect.String,
 					customMarshaler:   true,
 					customUnmarshaler: true,
 				},
@@ -251,3 +606,235 @@ func TestStructureFromStruct(t *testing.T) {
 		assert.Equal(t, s.mapping, m, s.msg)
 	}
 }
+
+func TestStructureFromStructCache(t *testing.T) {
+	type cached struct {
+		Field1 int    `csv:"f1"`
+		Field2 string `csv:"f2"`
+	}
+
+	first, err := structureFromStruct(cached{})
+	assert.NoError(t, err)
+	_, ok := fieldCache.Load(reflect.TypeOf(cached{}))
+	assert.True(t, ok, "mappings are cached by type")
+
+	first[0].fieldName = "changed"
+	second, err := structureFromStruct(cached{})
+	assert.NoError(t, err)
+	assert.Equal(t, "f1", second[0].fieldName, "callers cannot modify cached mappings")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m, err := structureFromStruct(cached{})
+			assert.NoError(t, err)
+			assert.Len(t, m, 2)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestStructureFromStructErrorNotCached(t *testing.T) {
+	type invalid struct {
+		Field1 int `csv:"f1,unknown"`
+	}
+
+	_, err := structureFromStruct(invalid{})
+	assert.Error(t, err)
+	_, ok := fieldCache.Load(reflect.TypeOf(invalid{}))
+	assert.False(t, ok)
+}
+
+func TestStructureFromStructInvalidTimeZone(t *testing.T) {
+	_, err := structureFromStruct(struct {
+		Field1 time.Time `csv:"f1,tz=Mars/Olympus_Mons"`
+	}{})
+	assert.ErrorContains(t, err, "invalid time zone found in csv tags: 'tz=Mars/Olympus_Mons'")
+}
+
+type Audit struct {
+	CreatedBy string `csv:"created_by"`
+	UpdatedBy string `csv:"updated_by"`
+}
+
+type audit struct {
+	Source string `csv:"source"`
+}
+
+type Address struct {
+	Street string `csv:"street"`
+	City   string `csv:"city|town"`
+}
+
+type recursive struct {
+	Name string `csv:"name"`
+	*recursive
+	Next *Recursive `csv:"next,inline"`
+}
+
+type Node struct {
+	Name string `csv:"name"`
+	*Node
+}
+
+type Recursive struct {
+	Name string     `csv:"name"`
+	Next *Recursive `csv:"next,inline"`
+}
+
+func TestStructureFromStructNested(t *testing.T) {
+	specs := []struct {
+		msg     string
+		s       interface{}
+		mapping []csvField
+		err     error
+	}{
+		{
+			msg: "embedded structs are flattened",
+			s: struct {
+				ID string `csv:"id"`
+				Audit
+				*audit
+				audit2 audit
+			}{},
+			mapping: []csvField{
+				csvField{fieldName: "id", fieldIndex: []int{0}, fieldType: reflect.String},
+				csvField{fieldName: "created_by", fieldIndex: []int{1, 0}, fieldType: reflect.String},
+				csvField{fieldName: "updated_by", fieldIndex: []int{1, 1}, fieldType: reflect.String},
+			},
+		},
+		{
+			msg: "unexported embedded structs are flattened unless they are pointers",
+			s: struct {
+				audit
+				ID string `csv:"id"`
+			}{},
+			mapping: []csvField{
+				csvField{fieldName: "source", fieldIndex: []int{0, 0}, fieldType: reflect.String},
+				csvField{fieldName: "id", fieldIndex: []int{1}, fieldType: reflect.String},
+			},
+		},
+		{
+			msg: "fields tagged - are skipped, embedded structs included",
+			s: struct {
+				ID     string `csv:"id"`
+				Audit  `csv:"-"`
+				Secret string `csv:"-"`
+				Dash   string `csv:"-,notempty"`
+			}{},
+			mapping: []csvField{
+				csvField{fieldName: "id", fieldIndex: []int{0}, fieldType: reflect.String},
+				csvField{notEmpty: true, fieldName: "-", fieldIndex: []int{3}, fieldType: reflect.String},
+			},
+		},
+		{
+			msg: "shallower fields hide embedded fields of the same name",
+			s: struct {
+				*Audit
+				CreatedBy int `csv:"created_by"`
+			}{},
+			mapping: []csvField{
+				csvField{fieldName: "updated_by", fieldIndex: []int{0, 1}, fieldType: reflect.String},
+				csvField{fieldName: "created_by", fieldIndex: []int{1}, fieldType: reflect.Int},
+			},
+		},
+		{
+			msg: "embedded fields of the same name at the same depth",
+			s: struct {
+				Audit
+				Other struct {
+					CreatedBy string `csv:"created_by"`
+				} `csv:",inline"`
+			}{},
+			err: fmt.Errorf("two attributes w/ csv field name: 'created_by'"),
+		},
+		{
+			msg: "inline structs are prefixed",
+			s: struct {
+				Home *Address `csv:"home|residence,inline"`
+				Work Address  `csv:"work,inline"`
+			}{},
+			mapping: []csvField{
+				csvField{
+					fieldName:  "home.street",
+					aliases:    []string{"residence.street"},
+					fieldIndex: []int{0, 0},
+					fieldType:  reflect.String,
+				},
+				csvField{
+					fieldName:  "home.city",
+					aliases:    []string{"home.town", "residence.city", "residence.town"},
+					fieldIndex: []int{0, 1},
+					fieldType:  reflect.String,
+				},
+				csvField{fieldName: "work.street", fieldIndex: []int{1, 0}, fieldType: reflect.String},
+				csvField{fieldName: "work.city", aliases: []string{"work.town"}, fieldIndex: []int{1, 1}, fieldType: reflect.String},
+			},
+		},
+		{
+			msg: "recursive embedded structs are not walked again",
+			s:   Node{},
+			mapping: []csvField{
+				csvField{fieldName: "name", fieldIndex: []int{0}, fieldType: reflect.String},
+			},
+		},
+		{
+			msg: "recursive inline structs",
+			s:   recursive{},
+			err: fmt.Errorf("inline field 'Next' cannot contain its own type csvutil.Recursive"),
+		},
+		{
+			msg: "inline on a non-struct field",
+			s: struct {
+				Field1 string `csv:"f1,inline"`
+			}{},
+			err: fmt.Errorf("inline option is only supported on struct fields, found string for field 'Field1'"),
+		},
+		{
+			msg: "inline field with other options",
+			s: struct {
+				Field1 Address `csv:"f1,inline,required"`
+			}{},
+			err: fmt.Errorf("inline field 'Field1' cannot have other csv tag options"),
+		},
+	}
+	for _, spec := range specs {
+		mapping, err := structureFromStruct(spec.s)
+		assert.Equal(t, spec.err, err, spec.msg)
+		if spec.err == nil {
+			assert.Equal(t, spec.mapping, mapping, spec.msg)
+		}
+	}
+}
+
+func TestStructTypeOf(t *testing.T) {
+	type S struct {
+		Field1 string `csv:"f1"`
+	}
+	structType := reflect.TypeOf(S{})
+	for _, dest := range []interface{}{
+		S{}, &S{}, (*S)(nil), []S{}, []*S{}, structType, reflect.TypeOf(&S{}), reflect.TypeOf([]*S{}),
+	} {
+		found, err := structTypeOf(dest)
+		assert.NoError(t, err, "%T", dest)
+		assert.Equal(t, structType, found, "%T", dest)
+	}
+
+	specs := []struct {
+		dest interface{}
+		err  error
+	}{
+		{dest: nil, err: fmt.Errorf("provided struct cannot be nil")},
+		{dest: reflect.Type(nil), err: fmt.Errorf("provided struct cannot be nil")},
+		{dest: 1, err: fmt.Errorf("expected a struct, pointer to struct, slice of structs or reflect.Type, found int")},
+		{dest: new(*S), err: fmt.Errorf("expected a struct, pointer to struct, slice of structs or reflect.Type, found **csvutil.S")},
+		{dest: map[string]S{}, err: fmt.Errorf("expected a struct, pointer to struct, slice of structs or reflect.Type, found map[string]csvutil.S")},
+		{dest: reflect.TypeOf(""), err: fmt.Errorf("expected a struct, pointer to struct, slice of structs or reflect.Type, found string")},
+	}
+	for _, spec := range specs {
+		_, err := structTypeOf(spec.dest)
+		assert.Equal(t, spec.err, err, "%T", spec.dest)
+	}
+}
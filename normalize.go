@@ -0,0 +1,69 @@
+package csvutil
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// HeaderNormalizer maps a header to the key it is matched on. A CSV header matches a struct
+// field when both normalize to the same key, and two CSV headers with the same key are
+// duplicates.
+type HeaderNormalizer func(header string) string
+
+// NormalizeHeaders makes the Decoder match CSV headers to struct fields using n instead of
+// the default LegacyHeaders. A nil n keeps the default.
+func NormalizeHeaders(n HeaderNormalizer) DecoderOption {
+	return func(d *Decoder) {
+		if n == nil {
+			n = LegacyHeaders
+		}
+		d.normalize = n
+	}
+}
+
+// ExactHeaders matches headers exactly as written, including case and whitespace.
+func ExactHeaders(header string) string {
+	return header
+}
+
+// LegacyHeaders lowercases and trims headers after removing every non-ASCII character.
+// This is the default, but it makes headers such as "Prénom" and "Prnom" collide and
+// cannot match headers written in other scripts.
+func LegacyHeaders(header string) string {
+	return normalizeHeader(header)
+}
+
+// FoldedHeaders trims headers and matches them case-insensitively using Unicode case
+// folding, after normalizing them to NFC so that composed and decomposed characters match.
+func FoldedHeaders(header string) string {
+	return norm.NFC.String(cases.Fold().String(norm.NFC.String(strings.TrimSpace(header))))
+}
+
+// WordHeaders matches headers on their letters and digits alone, ignoring case as
+// FoldedHeaders does, so "First Name", "first_name", "first-name" and "firstName" all match.
+func WordHeaders(header string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) {
+			return r
+		}
+		return -1
+	}, FoldedHeaders(header))
+}
+
+// normalizeHeader lowercases, trims whitespace and removes non-ascii characters
+func normalizeHeader(header string) string {
+	return strings.ToLower(strings.TrimSpace(strings.Map(dropNonASCII, header)))
+}
+
+// dropNonASCII is a strings.Map mapping that removes every non-ascii rune (including
+// invalid UTF-8, which decodes to utf8.RuneError).
+func dropNonASCII(r rune) rune {
+	if r >= utf8.RuneSelf {
+		return -1
+	}
+	return r
+}
@@ -0,0 +1,128 @@
+package csvutil
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderNormalizers(t *testing.T) {
+	specs := []struct {
+		msg        string
+		normalizer HeaderNormalizer
+		same       [][2]string
+		different  [][2]string
+	}{
+		{
+			msg:        "exact",
+			normalizer: ExactHeaders,
+			same:       [][2]string{{"First Name", "First Name"}, {"名前", "名前"}},
+			different:  [][2]string{{"First Name", "first name"}, {"id", " id"}},
+		},
+		{
+			msg:        "legacy",
+			normalizer: LegacyHeaders,
+			same:       [][2]string{{" First Name", "first name"}, {"Prénom", "prnom"}},
+			different:  [][2]string{{"first name", "first_name"}},
+		},
+		{
+			msg:        "folded",
+			normalizer: FoldedHeaders,
+			same: [][2]string{
+				{" Prénom ", "PRÉNOM"},
+				{"Prénom", "prénom"},
+				{"Straße", "STRASSE"},
+				{"名前", "名前"},
+			},
+			different: [][2]string{{"Prénom", "Prnom"}, {"first name", "first_name"}, {"名前", "名"}},
+		},
+		{
+			msg:        "words",
+			normalizer: WordHeaders,
+			same: [][2]string{
+				{"First Name", "first_name"},
+				{"first_name", "firstName"},
+				{"first-name", "FIRST NAME"},
+				{"Prénom Usuel", "prénom_usuel"},
+				{"名前", "名前"},
+			},
+			different: [][2]string{{"first name", "last name"}, {"Prénom", "Prnom"}},
+		},
+	}
+	for _, spec := range specs {
+		for _, pair := range spec.same {
+			assert.Equal(t, spec.normalizer(pair[0]), spec.normalizer(pair[1]), "%s: %q, %q", spec.msg, pair[0], pair[1])
+		}
+		for _, pair := range spec.different {
+			assert.NotEqual(t, spec.normalizer(pair[0]), spec.normalizer(pair[1]), "%s: %q, %q", spec.msg, pair[0], pair[1])
+		}
+	}
+}
+
+func TestDecoderNormalizeHeaders(t *testing.T) {
+	type person struct {
+		FirstName string `csv:"first_name"`
+		Given     string `csv:"Prénom"`
+		Name      string `csv:"名前"`
+	}
+
+	d, err := NewDecoder(strings.NewReader("First Name,PRÉNOM,名前\nAda,Augusta,エイダ\n"), person{}, NormalizeHeaders(WordHeaders))
+	assert.NoError(t, err)
+	var p person
+	assert.NoError(t, d.Read(&p))
+	assert.Equal(t, person{FirstName: "Ada", Given: "Augusta", Name: "エイダ"}, p)
+
+	_, err = NewDecoder(strings.NewReader("first_name,firstName\n"), person{}, NormalizeHeaders(WordHeaders))
+	assert.Equal(t, fmt.Errorf("saw header column 'firstname' twice: %w", ErrDuplicateHeader), err)
+
+	d, err = NewDecoder(strings.NewReader("First_Name,first_name\n"), person{}, NormalizeHeaders(ExactHeaders))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first_name"}, d.MatchedHeaders())
+	assert.Equal(t, []string{"First_Name"}, d.UnmatchedHeaders())
+
+	// the legacy normalizer drops non-ASCII characters, so "名前" cannot be told apart from an
+	// empty header and "Prénom" becomes "prnom"
+	d, err = NewDecoder(strings.NewReader("first_name,prnom\n"), person{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first_name", "Prénom"}, d.MatchedHeaders())
+
+	// a nil normalizer keeps the default
+	d, err = NewDecoder(strings.NewReader("First_Name,prnom\n"), person{}, NormalizeHeaders(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first_name", "Prénom"}, d.MatchedHeaders())
+}
+
+func TestDecoderNormalizedFieldNameCollision(t *testing.T) {
+	type words struct {
+		Snake string `csv:"first_name"`
+		Camel string `csv:"firstName"`
+	}
+	_, err := NewDecoder(strings.NewReader("First Name\nAda\n"), words{}, NormalizeHeaders(WordHeaders))
+	assert.Equal(t, fmt.Errorf("csv field names 'first_name' and 'firstName' both normalize to header 'firstname'"), err)
+
+	type legacy struct {
+		Accented string `csv:"Prénom"`
+		Plain    string `csv:"Prnom"`
+	}
+	_, err = NewDecoder(strings.NewReader("Prénom\nAda\n"), legacy{})
+	assert.Equal(t, fmt.Errorf("csv field names 'Prénom' and 'Prnom' both normalize to header 'prnom'"), err)
+
+	// both fields can be matched once the normalizer tells them apart
+	d, err := NewDecoder(strings.NewReader("Prénom,Prnom\nAda,Lovelace\n"), legacy{}, NormalizeHeaders(FoldedHeaders))
+	assert.NoError(t, err)
+	var l legacy
+	assert.NoError(t, d.Read(&l))
+	assert.Equal(t, legacy{Accented: "Ada", Plain: "Lovelace"}, l)
+
+	// aliases of a single field may normalize to the same header
+	type aliased struct {
+		Name string `csv:"first_name|firstName"`
+	}
+	d, err = NewDecoder(strings.NewReader("First Name\nAda\n"), aliased{}, NormalizeHeaders(WordHeaders))
+	assert.NoError(t, err)
+	var a aliased
+	assert.NoError(t, d.Read(&a))
+	assert.Equal(t, aliased{Name: "Ada"}, a)
+}
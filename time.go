@@ -0,0 +1,104 @@
+package csvutil
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// Special values of the format tag option, used in place of a time layout.
+const (
+	// formatUnix reads and writes whole seconds since the Unix epoch.
+	formatUnix = "unix"
+	// formatUnixMilli reads and writes milliseconds since the Unix epoch.
+	formatUnixMilli = "unixmilli"
+	// formatExcel reads and writes spreadsheet serial dates: days since 1899-12-30, with
+	// the time of day as the fractional part.
+	formatExcel = "excel"
+)
+
+// excelEpoch is day 0 of spreadsheet serial dates, in Unix seconds. It is 1899-12-30 rather
+// than 1900-01-01 to make up for spreadsheets counting 1900 as a leap year.
+var excelEpoch = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC).Unix()
+
+// parseTime parses a value with each of the layouts in turn, returning the first success.
+// Values without a zone are read in loc, or in UTC if loc is nil.
+func parseTime(strValue string, layouts []string, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	var firstErr error
+	for _, layout := range layouts {
+		t, err := parseTimeLayout(strValue, layout, loc)
+		if err == nil {
+			return t, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return time.Time{}, fmt.Errorf("failed to coerce value '%s' into time with format '%s': %w",
+		strValue, strings.Join(layouts, "|"), firstErr)
+}
+
+// parseTimeLayout parses a value with a single layout or special format.
+func parseTimeLayout(strValue, layout string, loc *time.Location) (time.Time, error) {
+	switch layout {
+	case formatUnix:
+		secs, err := strconv.ParseInt(strValue, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(secs, 0).In(loc), nil
+	case formatUnixMilli:
+		millis, err := strconv.ParseInt(strValue, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.UnixMilli(millis).In(loc), nil
+	case formatExcel:
+		serial, err := strconv.ParseFloat(strValue, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if math.IsNaN(serial) || math.IsInf(serial, 0) {
+			return time.Time{}, fmt.Errorf("invalid serial date %v", serial)
+		}
+		// serial dates count wall clock days, so the date is built in loc rather than by
+		// adding a duration to the epoch. The time of day is rounded to milliseconds to
+		// drop floating point noise.
+		days := math.Floor(serial)
+		millis := int(math.Round((serial - days) * float64(24*time.Hour/time.Millisecond)))
+		return time.Date(1899, time.December, 30+int(days), 0, 0, 0, millis*int(time.Millisecond), loc), nil
+	default:
+		return time.ParseInLocation(layout, strValue, loc)
+	}
+}
+
+// formatTime formats a time with a single layout or special format, after converting it
+// to loc unless loc is nil.
+func formatTime(t time.Time, layout string, loc *time.Location) string {
+	if loc != nil {
+		t = t.In(loc)
+	}
+	switch layout {
+	case formatUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	case formatUnixMilli:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	case formatExcel:
+		wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+		millis := (wall.Unix()-excelEpoch)*1000 + int64(wall.Nanosecond())/int64(time.Millisecond)
+		return strconv.FormatFloat(float64(millis)/float64(24*time.Hour/time.Millisecond), 'f', -1, 64)
+	default:
+		return t.Format(layout)
+	}
+}
@@ -0,0 +1,183 @@
+package csvutil
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAndFormatTime(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	specs := []struct {
+		msg      string
+		layout   string
+		loc      *time.Location
+		value    string
+		expected time.Time
+	}{
+		{
+			msg:      "date layout",
+			layout:   "2006-01-02",
+			value:    "2024-03-10",
+			expected: time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			msg:      "date layout in a time zone",
+			layout:   "01/02/2006 15:04",
+			loc:      newYork,
+			value:    "03/10/2024 09:30",
+			expected: time.Date(2024, 3, 10, 9, 30, 0, 0, newYork),
+		},
+		{
+			msg:      "unix seconds",
+			layout:   formatUnix,
+			value:    "1710063000",
+			expected: time.Date(2024, 3, 10, 9, 30, 0, 0, time.UTC),
+		},
+		{
+			msg:      "unix milliseconds",
+			layout:   formatUnixMilli,
+			value:    "1710063000250",
+			expected: time.Date(2024, 3, 10, 9, 30, 0, 250000000, time.UTC),
+		},
+		{
+			msg:      "excel serial date",
+			layout:   formatExcel,
+			value:    "45361",
+			expected: time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			msg:      "excel serial date and time",
+			layout:   formatExcel,
+			value:    "45361.75",
+			expected: time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			msg:      "excel serial date counts wall clock time in its time zone",
+			layout:   formatExcel,
+			loc:      newYork,
+			value:    "45361.5",
+			expected: time.Date(2024, 3, 10, 12, 0, 0, 0, newYork),
+		},
+		{
+			msg:      "excel serial date before the epoch",
+			layout:   formatExcel,
+			value:    "-1.25",
+			expected: time.Date(1899, 12, 28, 18, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, spec := range specs {
+		parsed, err := parseTime(spec.value, []string{spec.layout}, spec.loc)
+		if assert.NoError(t, err, spec.msg) {
+			assert.True(t, spec.expected.Equal(parsed), "%s: expected %s, found %s", spec.msg, spec.expected, parsed)
+		}
+		assert.Equal(t, spec.value, formatTime(spec.expected, spec.layout, spec.loc), spec.msg)
+	}
+}
+
+func TestParseTimeFallbackLayouts(t *testing.T) {
+	layouts := []string{"2006-01-02", "01/02/2006", formatUnix}
+	expected := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	for _, value := range []string{"2024-03-10", "03/10/2024", "1710028800"} {
+		parsed, err := parseTime(value, layouts, nil)
+		assert.NoError(t, err, value)
+		assert.True(t, expected.Equal(parsed), value)
+	}
+
+	_, err := parseTime("March 10", layouts, nil)
+	_, firstErr := time.Parse("2006-01-02", "March 10")
+	assert.Equal(t, fmt.Errorf("failed to coerce value 'March 10' into time with format '2006-01-02|01/02/2006|unix': %w", firstErr), err)
+
+	_, err = parseTime("NaN", []string{formatExcel}, nil)
+	assert.Error(t, err)
+}
+
+func TestFormatTimeConvertsToTimeZone(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+	utc := time.Date(2024, 3, 10, 2, 0, 0, 0, time.UTC)
+	assert.Equal(t, "2024-03-10", formatTime(utc, "2006-01-02", nil))
+	assert.Equal(t, "2024-03-09", formatTime(utc, "2006-01-02", newYork))
+	assert.Equal(t, "45360.875", formatTime(utc, formatExcel, newYork))
+}
+
+func TestTimeTagsRoundTrip(t *testing.T) {
+	type event struct {
+		Name     string         `csv:"name"`
+		Day      time.Time      `csv:"day,format=2006-01-02|01/02/2006"`
+		At       *time.Time     `csv:"at,format=2006-01-02 15:04,tz=America/New_York"`
+		Unix     time.Time      `csv:"unix,format=unix"`
+		Serial   time.Time      `csv:"serial,format=excel"`
+		Stamp    time.Time      `csv:"stamp"`
+		Duration time.Duration  `csv:"duration"`
+		Timeout  *time.Duration `csv:"timeout"`
+	}
+	csvFile := "name,day,at,unix,serial,stamp,duration,timeout\n" +
+		"launch,03/10/2024,2024-03-10 09:30,1710063000,45361.5,2024-03-10T09:30:00Z,1h30m0s,\n"
+
+	var events []event
+	assert.NoError(t, Unmarshal([]byte(csvFile), &events))
+	if assert.Len(t, events, 1) {
+		e := events[0]
+		newYork, _ := time.LoadLocation("America/New_York")
+		assert.True(t, time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC).Equal(e.Day))
+		if assert.NotNil(t, e.At) {
+			assert.True(t, time.Date(2024, 3, 10, 9, 30, 0, 0, newYork).Equal(*e.At))
+		}
+		assert.True(t, time.Date(2024, 3, 10, 9, 30, 0, 0, time.UTC).Equal(e.Unix))
+		assert.True(t, time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC).Equal(e.Serial))
+		assert.True(t, time.Date(2024, 3, 10, 9, 30, 0, 0, time.UTC).Equal(e.Stamp))
+		assert.Equal(t, 90*time.Minute, e.Duration)
+		assert.Nil(t, e.Timeout)
+	}
+
+	data, err := Marshal(events)
+	assert.NoError(t, err)
+	// the first layout is used for encoding
+	assert.Equal(t, strings.Replace(csvFile, "03/10/2024", "2024-03-10", 1), string(data))
+}
+
+func TestTimeTagsWithCommas(t *testing.T) {
+	type event struct {
+		Day time.Time `csv:"day,format=Jan 2, 2006,tz=America/New_York"`
+		At  time.Time `csv:"at,format=Mon, 02 Jan 2006 15:04:05 MST,required"`
+	}
+	csvFile := "day,at\n\"Mar 10, 2024\",\"Sun, 10 Mar 2024 09:30:00 UTC\"\n"
+
+	var events []event
+	assert.NoError(t, Unmarshal([]byte(csvFile), &events))
+	if assert.Len(t, events, 1) {
+		newYork, _ := time.LoadLocation("America/New_York")
+		assert.True(t, time.Date(2024, 3, 10, 0, 0, 0, 0, newYork).Equal(events[0].Day))
+		assert.True(t, time.Date(2024, 3, 10, 9, 30, 0, 0, time.UTC).Equal(events[0].At))
+	}
+
+	data, err := Marshal(events)
+	assert.NoError(t, err)
+	assert.Equal(t, csvFile, string(data))
+}
+
+func TestTimeTagsDecodeErrors(t *testing.T) {
+	type event struct {
+		Day      time.Time     `csv:"day,format=2006-01-02"`
+		Duration time.Duration `csv:"duration"`
+	}
+	var events []event
+	err := Unmarshal([]byte("day,duration\n2024-03-10,90\n"), &events)
+	var decodeErr *DecodeError
+	if assert.ErrorAs(t, err, &decodeErr) {
+		assert.Equal(t, "duration", decodeErr.Header)
+		assert.Contains(t, decodeErr.Error(), "failed to coerce value '90' into duration")
+	}
+
+	err = Unmarshal([]byte("day,duration\n10/03/2024,90s\n"), &events)
+	if assert.ErrorAs(t, err, &decodeErr) {
+		assert.Equal(t, "day", decodeErr.Header)
+		assert.Contains(t, decodeErr.Error(), "failed to coerce value '10/03/2024' into time with format '2006-01-02'")
+	}
+}
@@ -0,0 +1,110 @@
+package csvutil
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"reflect"
+)
+
+// TypedDecoder reads data from a CSV into structs of type T. It shares the mappings and
+// options of Decoder, but reads rows without checking the destination type on every call.
+type TypedDecoder[T any] struct {
+	Decoder
+}
+
+// NewTypedDecoder initializes itself with the headers of the CSV file to build mappings
+// to read data into structs of type T.
+func NewTypedDecoder[T any](r io.Reader, opts ...DecoderOption) (TypedDecoder[T], error) {
+	return NewTypedDecoderFromCSVReader[T](newCSVReader(r), opts...)
+}
+
+// NewTypedDecoderFromCSVReader intializes a typed decoder using the given csv.Reader.
+func NewTypedDecoderFromCSVReader[T any](csvR *csv.Reader, opts ...DecoderOption) (TypedDecoder[T], error) {
+	structType := reflect.TypeFor[T]()
+	if err := checkStructType(structType); err != nil {
+		return TypedDecoder[T]{}, err
+	}
+	d, err := NewDecoderFromCSVReader(csvR, structType, opts...)
+	if err != nil {
+		return TypedDecoder[T]{}, err
+	}
+	return TypedDecoder[T]{d}, nil
+}
+
+// Read decodes data from the next CSV row into a new T.
+// When there is no data left in the reader, an `io.EOF` is returned. If the row fails to
+// decode, the partially decoded value is returned along with the error.
+func (d TypedDecoder[T]) Read() (T, error) {
+	var v T
+	err := d.read(reflect.ValueOf(&v).Elem())
+	return v, err
+}
+
+// All returns an iterator over the remaining rows of the CSV, stopping cleanly at
+// `io.EOF`. Rows that fail to decode are yielded with their error and iteration moves on
+// to the next row; any other error (e.g. from the underlying reader) ends the iteration
+// after it is yielded.
+func (d TypedDecoder[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			v, err := d.Read()
+			if err == io.EOF {
+				return
+			}
+			if !yield(v, err) {
+				return
+			}
+			if err != nil && !isRowError(err) {
+				return
+			}
+		}
+	}
+}
+
+// isRowError returns true if err only affects the row it was returned for, so reading can
+// carry on with the next row.
+func isRowError(err error) bool {
+	var decodeErr *DecodeError
+	var rowErr *RowError
+	return errors.As(err, &decodeErr) || errors.As(err, &rowErr)
+}
+
+// TypedEncoder writes structs of type T into a CSV. It shares the mappings of Encoder, but
+// writes rows without checking the source type on every call.
+type TypedEncoder[T any] struct {
+	Encoder
+}
+
+// NewTypedEncoder prepares mappings from structs of type T to CSV based on struct tags.
+func NewTypedEncoder[T any](w io.Writer, opts ...EncoderOption) (TypedEncoder[T], error) {
+	return NewTypedEncoderFromCSVWriter[T](csv.NewWriter(w), opts...)
+}
+
+// NewTypedEncoderFromCSVWriter intializes a typed encoder using the given csv.Writer.
+func NewTypedEncoderFromCSVWriter[T any](csvW *csv.Writer, opts ...EncoderOption) (TypedEncoder[T], error) {
+	structType := reflect.TypeFor[T]()
+	if err := checkStructType(structType); err != nil {
+		return TypedEncoder[T]{}, err
+	}
+	e, err := NewEncoderFromCSVWriter(csvW, structType, opts...)
+	if err != nil {
+		return TypedEncoder[T]{}, err
+	}
+	return TypedEncoder[T]{e}, nil
+}
+
+// Write encodes the values of v into a CSV row and writes to the underlying io.Writer.
+func (e TypedEncoder[T]) Write(v T) error {
+	return e.write(reflect.ValueOf(v))
+}
+
+// checkStructType ensures a type parameter can be used to build mappings.
+func checkStructType(t reflect.Type) error {
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("type parameter must be a struct, found %s", t)
+	}
+	return nil
+}
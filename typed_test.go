@@ -0,0 +1,95 @@
+package csvutil
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type typedRow struct {
+	Name  string `csv:"name"`
+	Count int    `csv:"count"`
+}
+
+func TestTypedDecoderRead(t *testing.T) {
+	d, err := NewTypedDecoder[typedRow](strings.NewReader("count,name\n1,a\nx,b\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"count", "name"}, d.MatchedHeaders())
+	assert.True(t, d.r.ReuseRecord, "the reader is set up the same way as NewDecoder's")
+
+	row, err := d.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, typedRow{Name: "a", Count: 1}, row)
+
+	_, err = d.Read()
+	assert.ErrorAs(t, err, new(*DecodeError))
+
+	_, err = d.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestNewTypedDecoderNonStruct(t *testing.T) {
+	_, err := NewTypedDecoder[*typedRow](strings.NewReader("name\n"))
+	assert.Equal(t, errors.New("type parameter must be a struct, found *csvutil.typedRow"), err)
+}
+
+func TestTypedEncoderWrite(t *testing.T) {
+	var buf bytes.Buffer
+	e, err := NewTypedEncoder[typedRow](&buf)
+	assert.NoError(t, err)
+	assert.NoError(t, e.Write(typedRow{Name: "a", Count: 1}))
+	assert.NoError(t, e.Flush())
+	assert.Equal(t, "name,count\na,1\n", buf.String())
+
+	_, err = NewTypedEncoder[[]typedRow](&buf)
+	assert.Equal(t, errors.New("type parameter must be a struct, found []csvutil.typedRow"), err)
+}
+
+func TestTypedDecoderAll(t *testing.T) {
+	d, err := NewTypedDecoder[typedRow](strings.NewReader("name,count\na,1\nb,x\nc,3\n"))
+	assert.NoError(t, err)
+
+	var rows []typedRow
+	var errs []error
+	for row, err := range d.All() {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		rows = append(rows, row)
+	}
+	assert.Equal(t, []typedRow{{Name: "a", Count: 1}, {Name: "c", Count: 3}}, rows)
+	if assert.Len(t, errs, 1) {
+		assert.ErrorAs(t, errs[0], new(*DecodeError))
+	}
+}
+
+func TestTypedDecoderAllBreak(t *testing.T) {
+	d, err := NewTypedDecoder[typedRow](strings.NewReader("name,count\na,1\nb,2\nc,3\n"))
+	assert.NoError(t, err)
+
+	for row := range d.All() {
+		assert.Equal(t, "a", row.Name)
+		break
+	}
+	row, err := d.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, typedRow{Name: "b", Count: 2}, row, "rows after the break are left unread")
+}
+
+func TestTypedDecoderAllReaderError(t *testing.T) {
+	d, err := NewTypedDecoder[typedRow](strings.NewReader("name,count\na,1\n\"b,2\n"))
+	assert.NoError(t, err)
+
+	var errs []error
+	for _, err := range d.All() {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	assert.Len(t, errs, 1, "iteration stops after a malformed CSV row")
+}
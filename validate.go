@@ -0,0 +1,176 @@
+package csvutil
+
+import (
+	"cmp"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// validationRule is a validation option of a csv tag, such as "min=3" or "email". Its
+// parameter is parsed for the field's type when building mappings, so that Read only
+// needs to compare the decoded value.
+type validationRule struct {
+	name  string
+	param string
+	// the bounds are the parsed parameter of min, max and len: a number for numeric fields
+	// (nanoseconds for time.Duration), and a length for strings and slices. Only the bound
+	// matching the field's kind is set.
+	intBound   int64
+	uintBound  uint64
+	floatBound float64
+	// options are the accepted values of oneof, formatted the way values are compared
+	options []string
+	pattern *regexp.Regexp
+}
+
+// compileRule parses the parameter of a validation rule for a field of type t, which is
+// the type of the decoded value (the element type for pointers).
+func compileRule(r validationRule, t reflect.Type) (validationRule, error) {
+	kind := t.Kind()
+	isString := kind == reflect.String
+	isInt := kind >= reflect.Int && kind <= reflect.Int64
+	isUint := kind >= reflect.Uint && kind <= reflect.Uint64
+	isFloat := kind == reflect.Float32 || kind == reflect.Float64
+
+	var supported bool
+	var err error
+	switch r.name {
+	case "min", "max":
+		supported = true
+		switch {
+		case t == durationType:
+			var d time.Duration
+			d, err = time.ParseDuration(r.param)
+			r.intBound = int64(d)
+		case isInt:
+			r.intBound, err = strconv.ParseInt(r.param, 10, 64)
+		case isUint:
+			r.uintBound, err = strconv.ParseUint(r.param, 10, 64)
+		case isFloat:
+			r.floatBound, err = strconv.ParseFloat(r.param, 64)
+		case isString || kind == reflect.Slice:
+			r.intBound, err = parseLengthParam(r.param)
+		default:
+			supported = false
+		}
+	case "len":
+		supported = isString || kind == reflect.Slice
+		if supported {
+			r.intBound, err = parseLengthParam(r.param)
+		}
+	case "oneof":
+		supported = (isString || isInt || isUint) && t != durationType
+		if supported {
+			r.options = strings.Fields(r.param)
+			for i, option := range r.options {
+				// integers are compared in their canonical form, so "01" matches 1
+				if isInt {
+					var n int64
+					n, err = strconv.ParseInt(option, 10, 64)
+					r.options[i] = strconv.FormatInt(n, 10)
+				} else if isUint {
+					var n uint64
+					n, err = strconv.ParseUint(option, 10, 64)
+					r.options[i] = strconv.FormatUint(n, 10)
+				}
+				if err != nil {
+					break
+				}
+			}
+		}
+	case "regex":
+		supported = isString
+		if supported {
+			r.pattern, err = regexp.Compile(r.param)
+		}
+	case "email":
+		supported = isString
+	}
+	if !supported {
+		return validationRule{}, fmt.Errorf("%s option is not supported for %s", r.name, t)
+	}
+	if err != nil {
+		return validationRule{}, fmt.Errorf("invalid %s option '%s': %w", r.name, r.param, err)
+	}
+	return r, nil
+}
+
+// parseLengthParam parses the length bound of a string or slice.
+func parseLengthParam(param string) (int64, error) {
+	n, err := strconv.ParseInt(param, 10, 64)
+	if err == nil && n < 0 {
+		return 0, fmt.Errorf("length cannot be negative")
+	}
+	return n, err
+}
+
+// check reports whether the decoded value v satisfies the rule. v is never a pointer.
+func (r validationRule) check(v reflect.Value) bool {
+	switch r.name {
+	case "min", "max":
+		var c int
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			c = cmp.Compare(v.Int(), r.intBound)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			c = cmp.Compare(v.Uint(), r.uintBound)
+		case reflect.Float32, reflect.Float64:
+			c = cmp.Compare(v.Float(), r.floatBound)
+		default:
+			c = cmp.Compare(valueLength(v), r.intBound)
+		}
+		if r.name == "min" {
+			return c >= 0
+		}
+		return c <= 0
+	case "len":
+		return valueLength(v) == r.intBound
+	case "oneof":
+		var s string
+		switch v.Kind() {
+		case reflect.String:
+			s = v.String()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			s = strconv.FormatUint(v.Uint(), 10)
+		default:
+			s = strconv.FormatInt(v.Int(), 10)
+		}
+		return slices.Contains(r.options, s)
+	case "regex":
+		return r.pattern.MatchString(v.String())
+	case "email":
+		addr, err := mail.ParseAddress(v.String())
+		// reject addresses with a display name, such as "Ada <ada@example.com>"
+		return err == nil && addr.Address == v.String()
+	}
+	return false
+}
+
+// valueLength is the number of characters in a string, or of elements in a slice.
+func valueLength(v reflect.Value) int64 {
+	if v.Kind() == reflect.String {
+		return int64(utf8.RuneCountInString(v.String()))
+	}
+	return int64(v.Len())
+}
+
+// validate checks a decoded field against the rules of its mapping, returning a
+// *ValidationError for the first rule it fails.
+func validate(field reflect.Value, rules []validationRule) error {
+	if field.Kind() == reflect.Ptr {
+		field = field.Elem()
+	}
+	for _, r := range rules {
+		if !r.check(field) {
+			return &ValidationError{Rule: r.name, Param: r.param}
+		}
+	}
+	return nil
+}
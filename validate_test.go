@@ -0,0 +1,239 @@
+package csvutil
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileRule(t *testing.T) {
+	specs := []struct {
+		msg      string
+		rule     validationRule
+		t        reflect.Type
+		expected validationRule
+		err      error
+	}{
+		{
+			msg:      "min on a signed integer",
+			rule:     validationRule{name: "min", param: "-3"},
+			t:        reflect.TypeOf(int8(0)),
+			expected: validationRule{name: "min", param: "-3", intBound: -3},
+		},
+		{
+			msg:      "max on an unsigned integer",
+			rule:     validationRule{name: "max", param: "300"},
+			t:        reflect.TypeOf(uint(0)),
+			expected: validationRule{name: "max", param: "300", uintBound: 300},
+		},
+		{
+			msg:      "min on a float",
+			rule:     validationRule{name: "min", param: "0.5"},
+			t:        reflect.TypeOf(float32(0)),
+			expected: validationRule{name: "min", param: "0.5", floatBound: 0.5},
+		},
+		{
+			msg:      "max on a duration",
+			rule:     validationRule{name: "max", param: "1m30s"},
+			t:        durationType,
+			expected: validationRule{name: "max", param: "1m30s", intBound: int64(90 * time.Second)},
+		},
+		{
+			msg:      "len on a slice",
+			rule:     validationRule{name: "len", param: "2"},
+			t:        reflect.TypeOf([]int{}),
+			expected: validationRule{name: "len", param: "2", intBound: 2},
+		},
+		{
+			msg:      "oneof on strings",
+			rule:     validationRule{name: "oneof", param: "red green  blue"},
+			t:        reflect.TypeOf(""),
+			expected: validationRule{name: "oneof", param: "red green  blue", options: []string{"red", "green", "blue"}},
+		},
+		{
+			msg:      "oneof on integers",
+			rule:     validationRule{name: "oneof", param: "01 +2 3"},
+			t:        reflect.TypeOf(0),
+			expected: validationRule{name: "oneof", param: "01 +2 3", options: []string{"1", "2", "3"}},
+		},
+		{
+			msg:      "regex on a string",
+			rule:     validationRule{name: "regex", param: "^[A-Z]{2}$"},
+			t:        reflect.TypeOf(""),
+			expected: validationRule{name: "regex", param: "^[A-Z]{2}$", pattern: regexp.MustCompile("^[A-Z]{2}$")},
+		},
+		{
+			msg:  "invalid bound",
+			rule: validationRule{name: "min", param: "ten"},
+			t:    reflect.TypeOf(0),
+			err: fmt.Errorf("invalid min option 'ten': %w",
+				&strconv.NumError{Func: "ParseInt", Num: "ten", Err: strconv.ErrSyntax}),
+		},
+		{
+			msg:  "negative length",
+			rule: validationRule{name: "len", param: "-1"},
+			t:    reflect.TypeOf(""),
+			err:  fmt.Errorf("invalid len option '-1': %w", fmt.Errorf("length cannot be negative")),
+		},
+		{
+			msg:  "invalid oneof integer",
+			rule: validationRule{name: "oneof", param: "1 two"},
+			t:    reflect.TypeOf(uint8(0)),
+			err: fmt.Errorf("invalid oneof option '1 two': %w",
+				&strconv.NumError{Func: "ParseUint", Num: "two", Err: strconv.ErrSyntax}),
+		},
+		{
+			msg:  "len on a number",
+			rule: validationRule{name: "len", param: "2"},
+			t:    reflect.TypeOf(0),
+			err:  fmt.Errorf("len option is not supported for int"),
+		},
+		{
+			msg:  "email on a number",
+			rule: validationRule{name: "email"},
+			t:    reflect.TypeOf(0),
+			err:  fmt.Errorf("email option is not supported for int"),
+		},
+		{
+			msg:  "min on a time",
+			rule: validationRule{name: "min", param: "1"},
+			t:    timeType,
+			err:  fmt.Errorf("min option is not supported for time.Time"),
+		},
+	}
+	for _, spec := range specs {
+		rule, err := compileRule(spec.rule, spec.t)
+		if spec.err != nil {
+			assert.Equal(t, spec.err, err, spec.msg)
+			continue
+		}
+		assert.NoError(t, err, spec.msg)
+		assert.Equal(t, spec.expected, rule, spec.msg)
+	}
+
+	_, err := compileRule(validationRule{name: "regex", param: "("}, reflect.TypeOf(""))
+	assert.ErrorContains(t, err, "invalid regex option '(': error parsing regexp")
+}
+
+func TestValidate(t *testing.T) {
+	rule := func(name, param string, v interface{}) validationRule {
+		r, err := compileRule(validationRule{name: name, param: param}, reflect.TypeOf(v))
+		assert.NoError(t, err, "%s=%s", name, param)
+		return r
+	}
+	specs := []struct {
+		rule    validationRule
+		valid   []interface{}
+		invalid []interface{}
+	}{
+		{rule: rule("min", "-2", 0), valid: []interface{}{-2, 5}, invalid: []interface{}{-3}},
+		{rule: rule("max", "10", uint16(0)), valid: []interface{}{uint16(0), uint16(10)}, invalid: []interface{}{uint16(11)}},
+		{rule: rule("min", "0.5", 0.0), valid: []interface{}{0.5, 2.0}, invalid: []interface{}{0.25}},
+		{rule: rule("max", "1m", time.Duration(0)), valid: []interface{}{time.Minute}, invalid: []interface{}{time.Hour}},
+		{rule: rule("min", "2", ""), valid: []interface{}{"ab", "éé"}, invalid: []interface{}{"é"}},
+		{rule: rule("max", "2", []string{}), valid: []interface{}{[]string{"a", "b"}}, invalid: []interface{}{[]string{"a", "b", "c"}}},
+		{rule: rule("len", "3", ""), valid: []interface{}{"abc", "日本語"}, invalid: []interface{}{"ab", "abcd"}},
+		{rule: rule("oneof", "red green", ""), valid: []interface{}{"red", "green"}, invalid: []interface{}{"blue", "Red"}},
+		{rule: rule("oneof", "1 2", int64(0)), valid: []interface{}{int64(1)}, invalid: []interface{}{int64(3)}},
+		{rule: rule("oneof", "7", uint(0)), valid: []interface{}{uint(7)}, invalid: []interface{}{uint(8)}},
+		{rule: rule("regex", "^[A-Z]{2}$", ""), valid: []interface{}{"US"}, invalid: []interface{}{"USA", "us"}},
+		{
+			rule:    rule("email", "", ""),
+			valid:   []interface{}{"ada@example.com"},
+			invalid: []interface{}{"ada", "Ada <ada@example.com>", "ada@"},
+		},
+	}
+	for _, spec := range specs {
+		for _, v := range spec.valid {
+			assert.NoError(t, validate(reflect.ValueOf(v), []validationRule{spec.rule}), "%s=%s: %v", spec.rule.name, spec.rule.param, v)
+		}
+		for _, v := range spec.invalid {
+			assert.Equal(t, &ValidationError{Rule: spec.rule.name, Param: spec.rule.param},
+				validate(reflect.ValueOf(v), []validationRule{spec.rule}), "%s=%s: %v", spec.rule.name, spec.rule.param, v)
+		}
+	}
+}
+
+func TestDecoderValidation(t *testing.T) {
+	type S struct {
+		Name    string   `csv:"name,notempty,min=2,max=5"`
+		Age     *int     `csv:"age,min=0,max=130"`
+		Color   string   `csv:"color,oneof=red green blue,default=red"`
+		Email   string   `csv:"email,email"`
+		Tags    []string `csv:"tags,sep=|,len=2"`
+		Country string   `csv:"country,regex=^[A-Z]{2}(,[A-Z]{2})*$"`
+	}
+	csvFile := "name,age,color,email,tags,country\n" +
+		"ada,36,,ada@example.com,a|b,\"GB,US\"\n" +
+		"x,200,pink,not-an-email,a,gb\n"
+
+	d, err := NewDecoder(strings.NewReader(csvFile), S{}, CollectErrors())
+	assert.NoError(t, err)
+
+	var s S
+	assert.NoError(t, d.Read(&s))
+	age := 36
+	assert.Equal(t, S{Name: "ada", Age: &age, Color: "red", Email: "ada@example.com", Tags: []string{"a", "b"}, Country: "GB,US"}, s)
+
+	err = d.Read(&s)
+	var rowErr *RowError
+	if assert.ErrorAs(t, err, &rowErr) {
+		expected := []struct{ header, value, rule, param string }{
+			{"name", "x", "min", "2"},
+			{"age", "200", "max", "130"},
+			{"color", "pink", "oneof", "red green blue"},
+			{"email", "not-an-email", "email", ""},
+			{"tags", "a", "len", "2"},
+			{"country", "gb", "regex", "^[A-Z]{2}(,[A-Z]{2})*$"},
+		}
+		if assert.Len(t, rowErr.Errors, len(expected)) {
+			for i, e := range expected {
+				assert.Equal(t, e.header, rowErr.Errors[i].Header)
+				assert.Equal(t, e.value, rowErr.Errors[i].Value)
+				assert.Equal(t, &ValidationError{Rule: e.rule, Param: e.param}, rowErr.Errors[i].Err)
+			}
+		}
+	}
+
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "line 3, column 'age': value fails validation rule 'max=130'", rowErr.Errors[1].Error())
+	assert.Equal(t, "line 3, column 'email': value fails validation rule 'email'", rowErr.Errors[3].Error())
+}
+
+func TestValidationTagErrors(t *testing.T) {
+	specs := []struct {
+		s   interface{}
+		err error
+	}{
+		{
+			s: struct {
+				Field1 string `csv:"f1,min="`
+			}{},
+			err: fmt.Errorf("empty validation option found in csv tags: 'min='"),
+		},
+		{
+			s: struct {
+				Field1 time.Time `csv:"f1,max=3"`
+			}{},
+			err: fmt.Errorf("field 'Field1': %w", fmt.Errorf("max option is not supported for time.Time")),
+		},
+	}
+	for _, spec := range specs {
+		_, err := structureFromStruct(spec.s)
+		assert.Equal(t, spec.err, err)
+	}
+
+	// defaults are checked when building a Decoder
+	type S struct {
+		Field1 string `csv:"f1,oneof=a b,default=c"`
+	}
+	_, err := NewDecoder(strings.NewReader("f1\na\n"), S{})
+	assert.Equal(t, fmt.Errorf("invalid default value for field 'f1': %w", &ValidationError{Rule: "oneof", Param: "a b"}), err)
+}